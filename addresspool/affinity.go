@@ -0,0 +1,152 @@
+package addresspool
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/karlseguin/ccache/v2"
+)
+
+const (
+	defaultAffinityMaxKeys = 10000
+	defaultAffinityTTL     = 10 * time.Minute
+)
+
+// AffinityOptions configures the key affinity of Pool.GetAddressFor
+type AffinityOptions struct {
+	MaxKeys int64         // the max count of keys remembered, the least recently used ones are evicted, default 10000
+	TTL     time.Duration // a key is forgotten if it is not used in TTL, default 10m
+}
+
+// affinity remembers the address of each key, so a key stays on its address until it is unavailable,
+// even if the addresses are reset or new ones are added
+type affinity struct {
+	ttl     time.Duration
+	maxKeys int64
+	// cache is created by the first key, so the pools not using GetAddressFor do not start its goroutines.
+	// The stopped cache can not be used, nothing is remembered after the pool is closed
+	mutex   sync.RWMutex
+	cache   *ccache.Cache
+	stopped bool
+}
+
+func newAffinity(opts AffinityOptions) *affinity {
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = defaultAffinityMaxKeys
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = defaultAffinityTTL
+	}
+	return &affinity{
+		ttl:     opts.TTL,
+		maxKeys: opts.MaxKeys,
+	}
+}
+
+func (a *affinity) get(key string) string {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	if a.stopped || a.cache == nil {
+		return ""
+	}
+	item := a.cache.Get(key)
+	if item == nil || item.Expired() {
+		return ""
+	}
+	addr, _ := item.Value().(string)
+	return addr
+}
+
+func (a *affinity) set(key, addr string) {
+	a.mutex.RLock()
+	if a.cache == nil {
+		a.mutex.RUnlock()
+		a.initCache()
+		a.mutex.RLock()
+	}
+	defer a.mutex.RUnlock()
+	if a.stopped {
+		return
+	}
+	a.cache.Set(key, addr, a.ttl)
+}
+
+func (a *affinity) initCache() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.cache == nil && !a.stopped {
+		a.cache = ccache.New(ccache.Configure().MaxSize(a.maxKeys))
+	}
+}
+
+func (a *affinity) stop() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.stopped {
+		return
+	}
+	a.stopped = true
+	if a.cache != nil {
+		a.cache.Stop()
+	}
+}
+
+// GetAddressFor Get an available address of ProtocolRest for the key, the same key gets the same address
+// as long as it is available, e.g. the follow-up reads of a watch should reach the same server.
+// The address of a new key is chosen by rendezvous hashing over the available addresses,
+// it does not use the strategy. After the pool is closed, the key is not remembered any more
+func (p *Pool) GetAddressFor(key string) string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if addr := p.affinity.get(key); len(addr) > 0 {
+		if tier := p.tierOf(ProtocolRest, addr); tier != TierNone {
+			p.metrics.IncPick(ProtocolRest, tier, addr)
+			return addr
+		}
+	}
+
+	tier, addrs := p.getAvailableTier(ProtocolRest)
+	if len(addrs) == 0 {
+		// not remembered, to return to the same address once it is available
+		addr := rendezvous(p.defaultAddress, key)
+		if len(addr) > 0 {
+			p.metrics.IncPick(ProtocolRest, TierNone, addr)
+		}
+		return addr
+	}
+	addr := rendezvous(addrs, key)
+	p.affinity.set(key, addr)
+	p.metrics.IncPick(ProtocolRest, tier, addr)
+	return addr
+}
+
+// tierOf returns the tier of the available addr, TierNone if addr is not available
+func (p *Pool) tierOf(protocol, addr string) Tier {
+	for _, t := range p.tiers(protocol) {
+		for _, a := range t.addrs {
+			if a == addr && len(p.filterAvailableAddress([]string{a})) > 0 {
+				return t.tier
+			}
+		}
+	}
+	return TierNone
+}
+
+// rendezvous returns the address with the highest hash of key and address,
+// only the keys of a removed address are moved to the others
+func rendezvous(addrs []string, key string) string {
+	var best string
+	var bestScore uint64
+	for _, addr := range addrs {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(addr))
+		score := h.Sum64()
+		if len(best) == 0 || score > bestScore {
+			best, bestScore = addr, score
+		}
+	}
+	return best
+}
@@ -0,0 +1,112 @@
+package addresspool
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_rendezvous(t *testing.T) {
+	assert.Equal(t, "", rendezvous(nil, "key"))
+	addrs := []string{"127.0.0.1:30100", "127.0.0.2:30100", "127.0.0.3:30100"}
+	moved := 0
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		addr := rendezvous(addrs, key)
+		assert.Equal(t, addr, rendezvous([]string{addrs[2], addrs[0], addrs[1]}, key), "should not depend on the order")
+		if addr == addrs[2] {
+			continue
+		}
+		// only the keys of the removed address should move
+		if rendezvous(addrs[:2], key) != addr {
+			moved++
+		}
+	}
+	assert.Equal(t, 0, moved)
+}
+
+func TestPool_GetAddressFor(t *testing.T) {
+	addr1 := "127.0.0.1:30101"
+	addr2 := "127.0.0.1:30102"
+	addr3 := "127.0.0.1:30103"
+	p := NewPool([]string{addr1, addr2})
+	defer p.Close()
+	setStatus := func(status map[string]string) {
+		p.mutex.Lock()
+		p.status = status
+		p.mutex.Unlock()
+	}
+	setStatus(map[string]string{addr1: statusAvailable, addr2: statusAvailable})
+
+	addrs := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		addrs[key] = p.GetAddressFor(key)
+		assert.Equal(t, addrs[key], p.GetAddressFor(key))
+	}
+
+	t.Run("new address added, should not move the keys", func(t *testing.T) {
+		p.ResetAddress([]string{addr1, addr2, addr3})
+		setStatus(map[string]string{addr1: statusAvailable, addr2: statusAvailable, addr3: statusAvailable})
+		for key, addr := range addrs {
+			assert.Equal(t, addr, p.GetAddressFor(key))
+		}
+	})
+	t.Run("no address is available, should not forget the keys", func(t *testing.T) {
+		setStatus(map[string]string{})
+		for key := range addrs {
+			assert.NotEmpty(t, p.GetAddressFor(key))
+		}
+		setStatus(map[string]string{addr1: statusAvailable, addr2: statusAvailable, addr3: statusAvailable})
+		for key, addr := range addrs {
+			assert.Equal(t, addr, p.GetAddressFor(key))
+		}
+	})
+	t.Run("address unavailable, should move its keys only", func(t *testing.T) {
+		setStatus(map[string]string{addr2: statusAvailable, addr3: statusAvailable})
+		for key, addr := range addrs {
+			if addr == addr1 {
+				assert.NotEqual(t, addr1, p.GetAddressFor(key))
+				continue
+			}
+			assert.Equal(t, addr, p.GetAddressFor(key))
+		}
+	})
+}
+
+func TestPool_GetAddressFor_afterClose(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer svr.Close()
+	addr := svr.Listener.Addr().String()
+	p := NewPool([]string{addr})
+	assert.Equal(t, addr, p.GetAddressFor("key"))
+
+	p.Close()
+	p.Close()
+	assert.NotPanics(t, func() {
+		assert.Equal(t, addr, p.GetAddressFor("key"))
+		assert.Equal(t, addr, p.GetAddressFor("another"))
+		assert.Equal(t, addr, p.GetAvailableAddress())
+	})
+}
+
+func TestPool_GetAddressFor_lazyCache(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer svr.Close()
+	addr := svr.Listener.Addr().String()
+	p := NewPool([]string{addr})
+	defer p.Close()
+	assert.Equal(t, addr, p.GetAvailableAddress())
+	assert.Nil(t, p.affinity.cache, "should not be created if GetAddressFor is not used")
+
+	assert.Equal(t, addr, p.GetAddressFor("key"))
+	assert.NotNil(t, p.affinity.cache)
+
+	closed := NewPool([]string{addr})
+	closed.Close()
+	assert.Equal(t, addr, closed.GetAddressFor("key"))
+	assert.Nil(t, closed.affinity.cache, "should not be created after the pool is closed")
+}
@@ -0,0 +1,187 @@
+package addresspool
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/openlog"
+)
+
+const eventQueueSize = 128
+
+// EventType is the type of the pool event
+type EventType string
+
+const (
+	// EventEndpointUp an endpoint becomes available
+	EventEndpointUp EventType = "EndpointUp"
+	// EventEndpointDown an endpoint becomes unavailable, by the active probe or the outlier detection
+	EventEndpointDown EventType = "EndpointDown"
+	// EventEndpointRemoved an endpoint is removed from the pool, by ResetAddress or SetAddressByInstances
+	EventEndpointRemoved EventType = "EndpointRemoved"
+	// EventTierChanged the tier which addresses are picked from is changed, e.g. failover from same az to diff az
+	EventTierChanged EventType = "TierChanged"
+	// EventReadinessChanged the result of CheckReadiness is changed
+	EventReadinessChanged EventType = "ReadinessChanged"
+)
+
+// Tier is the address group which the available addresses are picked from,
+// the priority is TierSameAz > TierSameRegion > TierDiffAz > TierDefault
+type Tier string
+
+const (
+	TierNone       Tier = "none" // no address is available, the default addresses are used as is
+	TierSameAz     Tier = "sameAz"
+	TierSameRegion Tier = "sameRegion" // only used when Options.Locality is set
+	TierDiffAz     Tier = "diffAz"
+	TierDefault    Tier = "default"
+)
+
+// Event is a status transition of the pool
+type Event struct {
+	Type EventType
+	Time time.Time
+	// Address is set for EventEndpointUp, EventEndpointDown and EventEndpointRemoved
+	Address string
+	// Protocol, PrevTier and Tier are set for EventTierChanged
+	Protocol string
+	PrevTier Tier
+	Tier     Tier
+	// PrevReadiness and Readiness are set for EventReadinessChanged
+	PrevReadiness int
+	Readiness     int
+}
+
+// poolState is the snapshot of the pool to find out the transitions
+type poolState struct {
+	available map[string]bool // keyed by the addresses in the pool
+	tiers     map[string]Tier // keyed by protocol
+	readiness int
+}
+
+type eventBus struct {
+	mutex       sync.RWMutex
+	handlers    map[int]func(Event)
+	nextID      int
+	queue       chan Event
+	onceStarted sync.Once
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		handlers: make(map[int]func(Event)),
+		queue:    make(chan Event, eventQueueSize),
+	}
+}
+
+func (b *eventBus) subscribe(handler func(Event)) func() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+	return func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		delete(b.handlers, id)
+	}
+}
+
+// publish never blocks the caller, the event is dropped if the queue is full
+func (b *eventBus) publish(events ...Event) {
+	for _, e := range events {
+		select {
+		case b.queue <- e:
+		default:
+			openlog.Warn("address pool event queue is full, drop event: " + string(e.Type))
+		}
+	}
+}
+
+func (b *eventBus) start(quit <-chan struct{}) {
+	b.onceStarted.Do(func() {
+		go func() {
+			for {
+				select {
+				case e := <-b.queue:
+					b.dispatch(e)
+				case <-quit:
+					return
+				}
+			}
+		}()
+	})
+}
+
+func (b *eventBus) dispatch(e Event) {
+	b.mutex.RLock()
+	ids := make([]int, 0, len(b.handlers))
+	for id := range b.handlers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	handlers := make([]func(Event), 0, len(ids))
+	for _, id := range ids {
+		handlers = append(handlers, b.handlers[id])
+	}
+	b.mutex.RUnlock()
+
+	for _, h := range handlers {
+		h(e)
+	}
+}
+
+// diffState returns the events from prev to cur, no event if prev is nil
+func diffState(prev, cur *poolState, now time.Time) []Event {
+	if prev == nil {
+		return nil
+	}
+	var events []Event
+	addrs := make([]string, 0, len(cur.available))
+	for addr := range cur.available {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		available := cur.available[addr]
+		if available == prev.available[addr] {
+			continue
+		}
+		e := Event{Type: EventEndpointDown, Time: now, Address: addr}
+		if available {
+			e.Type = EventEndpointUp
+		}
+		events = append(events, e)
+	}
+	removed := make([]string, 0)
+	for addr := range prev.available {
+		if _, ok := cur.available[addr]; !ok {
+			removed = append(removed, addr)
+		}
+	}
+	sort.Strings(removed)
+	for _, addr := range removed {
+		events = append(events, Event{Type: EventEndpointRemoved, Time: now, Address: addr})
+	}
+	protocols := make([]string, 0, len(cur.tiers))
+	for protocol := range cur.tiers {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+	for _, protocol := range protocols {
+		prevTier, ok := prev.tiers[protocol]
+		if !ok {
+			prevTier = TierNone
+		}
+		if prevTier != cur.tiers[protocol] {
+			events = append(events, Event{Type: EventTierChanged, Time: now, Protocol: protocol,
+				PrevTier: prevTier, Tier: cur.tiers[protocol]})
+		}
+	}
+	if prev.readiness != cur.readiness {
+		events = append(events, Event{Type: EventReadinessChanged, Time: now,
+			PrevReadiness: prev.readiness, Readiness: cur.readiness})
+	}
+	return events
+}
@@ -0,0 +1,170 @@
+package addresspool
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/cari/discovery"
+	"github.com/stretchr/testify/assert"
+)
+
+func receiveEvents(ch <-chan Event, n int) []Event {
+	events := make([]Event, 0, n)
+	timeout := time.After(3 * time.Second)
+	for len(events) < n {
+		select {
+		case e := <-ch:
+			events = append(events, e)
+		case <-timeout:
+			return events
+		}
+	}
+	return events
+}
+
+func TestPool_Subscribe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {}))
+	addr := server.Listener.Addr().String()
+	p := NewPool([]string{addr})
+	defer p.Close()
+
+	ch := make(chan Event, 10)
+	cancel := p.Subscribe(func(e Event) {
+		ch <- e
+	})
+
+	t.Run("endpoint is down, should receive down and tier changed events", func(t *testing.T) {
+		server.Close()
+		p.checkConnectivity()
+		events := receiveEvents(ch, 2)
+		if assert.Len(t, events, 2) {
+			assert.Equal(t, EventEndpointDown, events[0].Type)
+			assert.Equal(t, addr, events[0].Address)
+			assert.Equal(t, EventTierChanged, events[1].Type)
+			assert.Equal(t, TierDefault, events[1].PrevTier)
+			assert.Equal(t, TierNone, events[1].Tier)
+		}
+	})
+	t.Run("readiness changed, should receive readiness event", func(t *testing.T) {
+		p.checkConnectivity()
+		p.checkConnectivity()
+		events := receiveEvents(ch, 1)
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, EventReadinessChanged, events[0].Type)
+			assert.Equal(t, ReadinessIndeterminate, events[0].PrevReadiness)
+			assert.Equal(t, ReadinessFailed, events[0].Readiness)
+		}
+	})
+	t.Run("cancel the subscription, should receive nothing", func(t *testing.T) {
+		cancel()
+		p.mutex.Lock()
+		p.status[addr] = statusAvailable
+		p.mutex.Unlock()
+		p.refreshState()
+		assert.Empty(t, receiveEvents(ch, 1))
+	})
+}
+
+func TestPool_Subscribe_outlier(t *testing.T) {
+	sameAzAddr := "127.0.0.1:30101"
+	diffAzAddr := "127.0.0.1:30102"
+	p := NewPool([]string{}, Options{OutlierDetection: &OutlierDetectionOptions{
+		ConsecutiveFailures: 1,
+		BaseEjectionTime:    500 * time.Millisecond,
+	}})
+	defer p.Close()
+	ch := make(chan Event, 10)
+	p.Subscribe(func(e Event) {
+		ch <- e
+	})
+
+	p.mutex.Lock()
+	p.sameAzAddress[ProtocolRest] = []string{sameAzAddr}
+	p.diffAzAddress[ProtocolRest] = []string{diffAzAddr}
+	p.status[sameAzAddr] = statusAvailable
+	p.status[diffAzAddr] = statusAvailable
+	p.mutex.Unlock()
+	p.refreshState()
+	events := receiveEvents(ch, 3)
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, EventEndpointUp, events[0].Type)
+		assert.Equal(t, EventEndpointUp, events[1].Type)
+		assert.Equal(t, EventTierChanged, events[2].Type)
+		assert.Equal(t, TierNone, events[2].PrevTier)
+		assert.Equal(t, TierSameAz, events[2].Tier)
+	}
+
+	p.ReportResult(sameAzAddr, errCall, 0)
+	events = receiveEvents(ch, 2)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, EventEndpointDown, events[0].Type)
+		assert.Equal(t, sameAzAddr, events[0].Address)
+		assert.Equal(t, EventTierChanged, events[1].Type)
+		assert.Equal(t, TierSameAz, events[1].PrevTier)
+		assert.Equal(t, TierDiffAz, events[1].Tier)
+	}
+
+	// re-admitted after the ejection time
+	events = receiveEvents(ch, 2)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, EventEndpointUp, events[0].Type)
+		assert.Equal(t, sameAzAddr, events[0].Address)
+		assert.Equal(t, EventTierChanged, events[1].Type)
+		assert.Equal(t, TierSameAz, events[1].Tier)
+	}
+}
+
+func findEvent(events []Event, typ EventType) *Event {
+	for i := range events {
+		if events[i].Type == typ {
+			return &events[i]
+		}
+	}
+	return nil
+}
+
+func TestPool_Subscribe_removed(t *testing.T) {
+	addr1 := "127.0.0.1:30101"
+	addr2 := "127.0.0.1:30102"
+	p := NewPool([]string{}, Options{ProbeOptions: &ProbeOptions{Interval: time.Hour}})
+	defer p.Close()
+	ch := make(chan Event, 10)
+	p.Subscribe(func(e Event) {
+		ch <- e
+	})
+
+	p.ResetAddress([]string{addr1, addr2})
+	p.mutex.Lock()
+	p.status[addr1] = statusAvailable
+	p.status[addr2] = statusAvailable
+	p.mutex.Unlock()
+	p.refreshState()
+	receiveEvents(ch, 3)
+
+	t.Run("reset without an address, should receive removed event", func(t *testing.T) {
+		p.ResetAddress([]string{addr1})
+		e := findEvent(receiveEvents(ch, 2), EventEndpointRemoved)
+		if assert.NotNil(t, e) {
+			assert.Equal(t, addr2, e.Address)
+		}
+		p.stateMutex.Lock()
+		assert.True(t, p.state.available[addr1], "status of the kept address should not be changed before probing")
+		p.stateMutex.Unlock()
+	})
+	t.Run("set by instances without an address, should receive removed event", func(t *testing.T) {
+		assert.NoError(t, p.SetAddressByInstances([]*discovery.MicroServiceInstance{restInstance(addr2)}))
+		p.mutex.Lock()
+		p.status[addr2] = statusAvailable
+		p.mutex.Unlock()
+		p.refreshState()
+		receiveEvents(ch, 2)
+
+		assert.NoError(t, p.SetAddressByInstances([]*discovery.MicroServiceInstance{restInstance(addr1)}))
+		e := findEvent(receiveEvents(ch, 2), EventEndpointRemoved)
+		if assert.NotNil(t, e) {
+			assert.Equal(t, addr2, e.Address)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package addresspool
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/go-chassis/openlog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const grpcProbeTimeout = 5 * time.Second
+
+// GrpcProbeOptions is used to probe the endpoints of ProtocolGrpc by the standard grpc.health.v1.Health/Check service
+type GrpcProbeOptions struct {
+	// Service the service name to check, the overall health of the server is checked if not set
+	Service string
+	// TLSConfig is used to connect the endpoints, plaintext is used if not set
+	TLSConfig *tls.Config
+	// Metadata is added to each check request, e.g. authorization with a rbac token
+	Metadata map[string]string
+	// TreatUnimplementedAsHealthy the endpoint which does not implement the health service is available
+	// if it is connected, to compatible with the old servers. It is unavailable by default
+	TreatUnimplementedAsHealthy bool
+}
+
+func (p *Pool) doCheckConnectivityWithGrpc(ctx context.Context, endpoint string) error {
+	ctx, cancel := context.WithTimeout(ctx, p.probeTimeout(grpcProbeTimeout))
+	defer cancel()
+
+	creds := insecure.NewCredentials()
+	if p.grpcProbeOptions.TLSConfig != nil {
+		creds = credentials.NewTLS(p.grpcProbeOptions.TLSConfig)
+	}
+	conn, err := grpc.DialContext(ctx, endpoint, grpc.WithTransportCredentials(creds),
+		grpc.WithBlock(), grpc.FailOnNonTempDialError(true))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := conn.Close()
+		if err != nil {
+			openlog.Error(fmt.Sprintf("close grpc conn failed when check connectivity: %s", err))
+		}
+	}()
+
+	for k, v := range p.grpcProbeOptions.Metadata {
+		ctx = metadata.AppendToOutgoingContext(ctx, k, v)
+	}
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: p.grpcProbeOptions.Service,
+	})
+	if err != nil {
+		if status.Code(err) == codes.Unimplemented && p.grpcProbeOptions.TreatUnimplementedAsHealthy {
+			return nil
+		}
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("grpc health status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package addresspool
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func newGrpcServer(t *testing.T, withHealth bool) (*grpc.Server, *health.Server, string) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{},
+		info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if v := md.Get("authorization"); len(v) > 0 && v[0] != "Bearer token" {
+			return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_UNKNOWN}, nil
+		}
+		return handler(ctx, req)
+	}))
+	var healthServer *health.Server
+	if withHealth {
+		healthServer = health.NewServer()
+		healthpb.RegisterHealthServer(server, healthServer)
+	}
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	return server, healthServer, lis.Addr().String()
+}
+
+func TestPool_doCheckConnectivityWithGrpc(t *testing.T) {
+	server, healthServer, addr := newGrpcServer(t, true)
+	healthServer.SetServingStatus("registry", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("config", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	p := NewPool([]string{addr}, Options{GrpcProbeOptions: &GrpcProbeOptions{}})
+	defer p.Close()
+	assert.Equal(t, statusAvailable, p.status[addr])
+
+	t.Run("check service", func(t *testing.T) {
+		p.grpcProbeOptions.Service = "registry"
+		assert.NoError(t, p.doCheckConnectivityWithGrpc(context.Background(), addr))
+		p.grpcProbeOptions.Service = "config"
+		assert.Error(t, p.doCheckConnectivityWithGrpc(context.Background(), addr))
+		p.grpcProbeOptions.Service = "not-exist"
+		assert.Error(t, p.doCheckConnectivityWithGrpc(context.Background(), addr))
+		p.grpcProbeOptions.Service = ""
+	})
+	t.Run("check with metadata", func(t *testing.T) {
+		p.grpcProbeOptions.Metadata = map[string]string{"authorization": "Bearer token"}
+		assert.NoError(t, p.doCheckConnectivityWithGrpc(context.Background(), addr))
+		p.grpcProbeOptions.Metadata = map[string]string{"authorization": "Bearer wrong"}
+		assert.Error(t, p.doCheckConnectivityWithGrpc(context.Background(), addr))
+		p.grpcProbeOptions.Metadata = nil
+	})
+	t.Run("server stopped, should be unavailable", func(t *testing.T) {
+		server.Stop()
+		p.checkConnectivity()
+		assert.Equal(t, statusUnavailable, p.status[addr])
+	})
+}
+
+func TestPool_doCheckConnectivityWithGrpc_unimplemented(t *testing.T) {
+	server, _, addr := newGrpcServer(t, false)
+	defer server.Stop()
+
+	p := NewPool([]string{}, Options{GrpcProbeOptions: &GrpcProbeOptions{}})
+	defer p.Close()
+	assert.Error(t, p.doCheckConnectivityWithGrpc(context.Background(), addr))
+	p.grpcProbeOptions.TreatUnimplementedAsHealthy = true
+	assert.NoError(t, p.doCheckConnectivityWithGrpc(context.Background(), addr))
+}
+
+func TestPool_probeByProtocol(t *testing.T) {
+	server, healthServer, grpcAddr := newGrpcServer(t, true)
+	defer server.Stop()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	httpServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {}))
+	defer httpServer.Close()
+	restAddr := httpServer.Listener.Addr().String()
+
+	start := time.Now()
+	p := NewPool([]string{restAddr}, Options{
+		HttpProbeOptions: &HttpProbeOptions{Protocol: "http", Path: "/health"},
+		GrpcProbeOptions: &GrpcProbeOptions{},
+	})
+	defer p.Close()
+	assert.Less(t, int64(time.Since(start)), int64(time.Second), "http endpoint should not be probed by grpc")
+	assert.Equal(t, restAddr, p.GetAvailableAddress())
+
+	assert.NoError(t, p.doCheckConnectivityFor(context.Background(), ProtocolRest, restAddr))
+	assert.NoError(t, p.doCheckConnectivityFor(context.Background(), ProtocolGrpc, grpcAddr))
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	assert.Error(t, p.doCheckConnectivityFor(context.Background(), ProtocolGrpc, grpcAddr))
+}
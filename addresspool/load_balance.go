@@ -2,8 +2,14 @@ package addresspool
 
 import (
 	"errors"
+	"hash/crc32"
 	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // ErrNoneAvailable create a new error with Message No available
@@ -25,3 +31,223 @@ func RoundRobin(eps []string) Next {
 		return node, nil
 	}
 }
+
+// Strategy picks one address from the available addresses of a pool.
+// Implementations hold their own state, so one instance should serve only one pool
+type Strategy interface {
+	// Pick returns one of addrs, key is an optional caller-supplied hint,
+	// it is empty when the caller does not provide one
+	Pick(addrs []string, key string) (string, error)
+}
+
+// InFlightTracker is implemented by strategies which need to know when
+// a call to a picked address is finished
+type InFlightTracker interface {
+	Done(addr string)
+}
+
+type roundRobinStrategy struct {
+	counter uint32
+}
+
+// NewRoundRobinStrategy returns a strategy which gives the addresses in sequence
+func NewRoundRobinStrategy() Strategy {
+	return &roundRobinStrategy{counter: rand.Uint32()}
+}
+
+func (s *roundRobinStrategy) Pick(addrs []string, _ string) (string, error) {
+	if len(addrs) == 0 {
+		return "", ErrNoneAvailable
+	}
+	n := atomic.AddUint32(&s.counter, 1)
+	return addrs[int(n%uint32(len(addrs)))], nil
+}
+
+type randomStrategy struct {
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+// NewRandomStrategy returns a strategy which gives a random address
+func NewRandomStrategy() Strategy {
+	return &randomStrategy{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (s *randomStrategy) Pick(addrs []string, _ string) (string, error) {
+	if len(addrs) == 0 {
+		return "", ErrNoneAvailable
+	}
+	s.mutex.Lock()
+	n := s.rand.Intn(len(addrs))
+	s.mutex.Unlock()
+	return addrs[n], nil
+}
+
+type weightedRoundRobinStrategy struct {
+	mutex   sync.Mutex
+	weights map[string]int
+	current map[string]int
+}
+
+// NewWeightedRoundRobinStrategy returns a smooth weighted round-robin strategy,
+// the address which is not in weights has weight 1
+func NewWeightedRoundRobinStrategy(weights map[string]int) Strategy {
+	s := &weightedRoundRobinStrategy{
+		weights: make(map[string]int, len(weights)),
+		current: make(map[string]int),
+	}
+	for addr, w := range weights {
+		s.weights[addr] = w
+	}
+	return s
+}
+
+func (s *weightedRoundRobinStrategy) Pick(addrs []string, _ string) (string, error) {
+	if len(addrs) == 0 {
+		return "", ErrNoneAvailable
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current := make(map[string]int, len(addrs)) // drop the state of the addresses gone
+	best := ""
+	total := 0
+	for _, addr := range addrs {
+		w, ok := s.weights[addr]
+		if !ok {
+			w = 1
+		}
+		if w <= 0 {
+			continue
+		}
+		current[addr] = s.current[addr] + w
+		total += w
+		if best == "" || current[addr] > current[best] {
+			best = addr
+		}
+	}
+	if best == "" {
+		return "", ErrNoneAvailable
+	}
+	current[best] -= total
+	s.current = current
+	return best, nil
+}
+
+type leastInFlightStrategy struct {
+	mutex    sync.Mutex
+	inFlight map[string]int
+	counter  uint32
+}
+
+// NewLeastInFlightStrategy returns a strategy which gives the address with the
+// fewest calls in flight, the caller MUST call Pool.Done after each call finished
+func NewLeastInFlightStrategy() Strategy {
+	return &leastInFlightStrategy{inFlight: make(map[string]int)}
+}
+
+func (s *leastInFlightStrategy) Pick(addrs []string, _ string) (string, error) {
+	if len(addrs) == 0 {
+		return "", ErrNoneAvailable
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// start from a moving offset, so that the ties are broken in turn
+	s.counter++
+	offset := int(s.counter % uint32(len(addrs)))
+	best := addrs[offset]
+	for j := 1; j < len(addrs); j++ {
+		addr := addrs[(offset+j)%len(addrs)]
+		if s.inFlight[addr] < s.inFlight[best] {
+			best = addr
+		}
+	}
+	s.inFlight[best]++
+	return best, nil
+}
+
+func (s *leastInFlightStrategy) Done(addr string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.inFlight[addr] <= 1 {
+		delete(s.inFlight, addr)
+		return
+	}
+	s.inFlight[addr]--
+}
+
+const defaultHashReplicas = 100
+
+type hashRing struct {
+	hashes []uint32
+	nodes  map[uint32]string
+}
+
+type consistentHashStrategy struct {
+	mutex    sync.Mutex
+	replicas int
+	ringKey  string
+	ring     *hashRing
+	fallback Strategy
+}
+
+// NewConsistentHashStrategy returns a strategy which maps the caller-supplied key
+// to an address on a hash ring, replicas is the virtual nodes count of each address.
+// Picks without a key are given in sequence
+func NewConsistentHashStrategy(replicas int) Strategy {
+	if replicas <= 0 {
+		replicas = defaultHashReplicas
+	}
+	return &consistentHashStrategy{replicas: replicas, fallback: NewRoundRobinStrategy()}
+}
+
+func (s *consistentHashStrategy) Pick(addrs []string, key string) (string, error) {
+	if len(addrs) == 0 {
+		return "", ErrNoneAvailable
+	}
+	if len(key) == 0 {
+		return s.fallback.Pick(addrs, key)
+	}
+
+	ring := s.getRing(addrs)
+	h := crc32.ChecksumIEEE([]byte(key))
+	idx := sort.Search(len(ring.hashes), func(n int) bool { return ring.hashes[n] >= h })
+	if idx == len(ring.hashes) {
+		idx = 0
+	}
+	return ring.nodes[ring.hashes[idx]], nil
+}
+
+// getRing returns the ring of addrs, it is rebuilt only when the address set changed
+func (s *consistentHashStrategy) getRing(addrs []string) *hashRing {
+	sorted := make([]string, len(addrs))
+	copy(sorted, addrs)
+	sort.Strings(sorted)
+	ringKey := strings.Join(sorted, ",")
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ring != nil && s.ringKey == ringKey {
+		return s.ring
+	}
+
+	ring := &hashRing{
+		hashes: make([]uint32, 0, len(sorted)*s.replicas),
+		nodes:  make(map[uint32]string, len(sorted)*s.replicas),
+	}
+	for _, addr := range sorted {
+		for r := 0; r < s.replicas; r++ {
+			h := crc32.ChecksumIEEE([]byte(strconv.Itoa(r) + "#" + addr))
+			if _, exist := ring.nodes[h]; exist {
+				continue
+			}
+			ring.nodes[h] = addr
+			ring.hashes = append(ring.hashes, h)
+		}
+	}
+	sort.Slice(ring.hashes, func(a, b int) bool { return ring.hashes[a] < ring.hashes[b] })
+	s.ring = ring
+	s.ringKey = ringKey
+	return ring
+}
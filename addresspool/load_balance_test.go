@@ -1,6 +1,7 @@
 package addresspool_test
 
 import (
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -40,3 +41,119 @@ func TestLoadbalance(t *testing.T) {
 	_, err := next()
 	assert.NoError(t, err)
 }
+
+func TestStrategyEmpty(t *testing.T) {
+	strategies := []addresspool.Strategy{
+		addresspool.NewRoundRobinStrategy(),
+		addresspool.NewRandomStrategy(),
+		addresspool.NewWeightedRoundRobinStrategy(nil),
+		addresspool.NewLeastInFlightStrategy(),
+		addresspool.NewConsistentHashStrategy(0),
+	}
+	for _, s := range strategies {
+		_, err := s.Pick(nil, "key")
+		assert.Equal(t, addresspool.ErrNoneAvailable, err)
+	}
+}
+
+func TestRoundRobinStrategy(t *testing.T) {
+	eps := []string{"s1", "s2", "s3"}
+	s1 := addresspool.NewRoundRobinStrategy()
+	s2 := addresspool.NewRoundRobinStrategy()
+
+	t.Run("each address should be picked in turn", func(t *testing.T) {
+		counts := make(map[string]int)
+		for i := 0; i < 30; i++ {
+			addr, err := s1.Pick(eps, "")
+			assert.NoError(t, err)
+			counts[addr]++
+		}
+		assert.Equal(t, map[string]int{"s1": 10, "s2": 10, "s3": 10}, counts)
+	})
+	t.Run("picks of another strategy should not skew the sequence", func(t *testing.T) {
+		prev, _ := s1.Pick(eps, "")
+		for i := 0; i < 10; i++ {
+			_, _ = s2.Pick(eps, "")
+			next, _ := s1.Pick(eps, "")
+			assert.NotEqual(t, prev, next)
+			prev = next
+		}
+	})
+}
+
+func TestRandomStrategy(t *testing.T) {
+	eps := []string{"s1", "s2"}
+	s := addresspool.NewRandomStrategy()
+	for i := 0; i < 10; i++ {
+		addr, err := s.Pick(eps, "")
+		assert.NoError(t, err)
+		assert.Contains(t, eps, addr)
+	}
+}
+
+func TestWeightedRoundRobinStrategy(t *testing.T) {
+	eps := []string{"s1", "s2", "s3"}
+	s := addresspool.NewWeightedRoundRobinStrategy(map[string]int{"s1": 3, "s3": 0})
+
+	counts := make(map[string]int)
+	for i := 0; i < 8; i++ {
+		addr, err := s.Pick(eps, "")
+		assert.NoError(t, err)
+		counts[addr]++
+	}
+	assert.Equal(t, map[string]int{"s1": 6, "s2": 2}, counts)
+
+	_, err := s.Pick([]string{"s3"}, "")
+	assert.Equal(t, addresspool.ErrNoneAvailable, err)
+}
+
+func TestLeastInFlightStrategy(t *testing.T) {
+	eps := []string{"s1", "s2"}
+	s := addresspool.NewLeastInFlightStrategy()
+	tracker := s.(addresspool.InFlightTracker)
+
+	first, _ := s.Pick(eps, "")
+	second, _ := s.Pick(eps, "")
+	assert.NotEqual(t, first, second)
+
+	tracker.Done(second)
+	for i := 0; i < 3; i++ {
+		addr, _ := s.Pick(eps, "")
+		assert.Equal(t, second, addr)
+		tracker.Done(addr)
+	}
+}
+
+func TestConsistentHashStrategy(t *testing.T) {
+	eps := []string{"s1", "s2", "s3", "s4"}
+	s := addresspool.NewConsistentHashStrategy(0)
+
+	mapping := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		addr, err := s.Pick(eps, key)
+		assert.NoError(t, err)
+		mapping[key] = addr
+	}
+	t.Run("same key should get same address", func(t *testing.T) {
+		for key, want := range mapping {
+			addr, _ := s.Pick([]string{"s4", "s3", "s2", "s1"}, key)
+			assert.Equal(t, want, addr)
+		}
+	})
+	t.Run("remove one address, only its keys should move", func(t *testing.T) {
+		for key, want := range mapping {
+			addr, _ := s.Pick([]string{"s1", "s2", "s3"}, key)
+			if want != "s4" {
+				assert.Equal(t, want, addr)
+				continue
+			}
+			assert.NotEqual(t, "s4", addr)
+		}
+	})
+	t.Run("empty key, should pick in sequence", func(t *testing.T) {
+		a, _ := s.Pick(eps, "")
+		b, _ := s.Pick(eps, "")
+		assert.NotEqual(t, a, b)
+	})
+}
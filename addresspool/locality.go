@@ -0,0 +1,83 @@
+package addresspool
+
+import (
+	"math/rand"
+)
+
+// Locality is the region and az of the pool user, it is compared with the DataCenterInfo of the instances
+// to build the locality tiers: same az > same region > any
+type Locality struct {
+	Region        string
+	AvailableZone string
+}
+
+// tierAddress is the addresses of one tier
+type tierAddress struct {
+	tier  Tier
+	addrs []string
+}
+
+// tiers returns the addresses of the protocol in order of priority
+func (p *Pool) tiers(protocol string) []tierAddress {
+	tiers := []tierAddress{
+		{tier: TierSameAz, addrs: p.sameAzAddress[protocol]},
+		{tier: TierSameRegion, addrs: p.sameRegionAddress[protocol]},
+		{tier: TierDiffAz, addrs: p.diffAzAddress[protocol]},
+	}
+	if protocol == ProtocolRest {
+		tiers = append(tiers, tierAddress{tier: TierDefault, addrs: p.defaultAddress})
+	}
+	return tiers
+}
+
+// classify returns the tier of the group by the locality if the pool and the group both have it,
+// otherwise the group is in same az if any address of it is one of the default addresses
+func (p *Pool) classify(g *azGroup) Tier {
+	if p.locality != nil && (len(g.region) > 0 || len(g.az) > 0) {
+		sameRegion := len(p.locality.Region) > 0 && g.region == p.locality.Region
+		if len(p.locality.AvailableZone) > 0 && g.az == p.locality.AvailableZone &&
+			(sameRegion || len(p.locality.Region) == 0 || len(g.region) == 0) {
+			// the az name is unique in the region, it is the same az if either region is unknown
+			return TierSameAz
+		}
+		if sameRegion {
+			return TierSameRegion
+		}
+		return TierDiffAz
+	}
+	if p.isSameAzGroup(g) {
+		return TierSameAz
+	}
+	return TierDiffAz
+}
+
+// pickTier returns the tier to pick address from, the traffic spills over to the next tier
+// partly when the healthy ratio of a tier is below Options.SpilloverThreshold
+func (p *Pool) pickTier(protocol string) (Tier, []string) {
+	lastTier := TierNone
+	var last []string
+	for _, t := range p.tiers(protocol) {
+		addrs := p.filterAvailableAddress(t.addrs)
+		if len(addrs) == 0 {
+			continue
+		}
+		if !p.spillover(len(addrs), len(t.addrs)) {
+			return t.tier, addrs
+		}
+		lastTier, last = t.tier, addrs
+	}
+	return lastTier, last
+}
+
+// spillover decides whether a pick spills over to the next tier,
+// the probability is (1 - healthy ratio / threshold)
+func (p *Pool) spillover(healthy, total int) bool {
+	if p.spilloverThreshold <= 0 {
+		return false
+	}
+	load := float64(healthy) / float64(total) / p.spilloverThreshold
+	if load >= 1 {
+		return false
+	}
+	return rand.Float64() >= load
+}
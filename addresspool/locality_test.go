@@ -0,0 +1,107 @@
+package addresspool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/cari/discovery"
+)
+
+func TestPool_SetAddressByInstances_locality(t *testing.T) {
+	p := NewPool([]string{"192.168.9.1:30100"}, Options{
+		Locality: &Locality{Region: "cn-north", AvailableZone: "az1"},
+	})
+	defer p.Close()
+
+	err := p.SetAddressByInstances([]*discovery.MicroServiceInstance{
+		{
+			Endpoints:      []string{"rest://192.168.1.1:30100"},
+			DataCenterInfo: &discovery.DataCenterInfo{Region: "cn-north", AvailableZone: "az1"},
+		},
+		{
+			Endpoints:      []string{"rest://192.168.2.1:30100"},
+			DataCenterInfo: &discovery.DataCenterInfo{Region: "cn-north", AvailableZone: "az2"},
+		},
+		{
+			Endpoints:      []string{"rest://192.168.3.1:30100"},
+			DataCenterInfo: &discovery.DataCenterInfo{Region: "cn-south", AvailableZone: "az1"},
+		},
+		{
+			Endpoints:      []string{"rest://192.168.4.1:30100"},
+			DataCenterInfo: &discovery.DataCenterInfo{AvailableZone: "az1"},
+		},
+		{
+			Endpoints: []string{"rest://192.168.9.1:30100"}, // no locality, in the default addresses
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"192.168.1.1:30100", "192.168.4.1:30100", "192.168.9.1:30100"}, p.sameAzAddress[ProtocolRest])
+	assert.Equal(t, []string{"192.168.2.1:30100"}, p.sameRegionAddress[ProtocolRest])
+	assert.Equal(t, []string{"192.168.3.1:30100"}, p.diffAzAddress[ProtocolRest])
+	assert.Len(t, p.probeTargets(), 5)
+
+	p.mutex.Lock()
+	p.status = map[string]string{
+		"192.168.2.1:30100": statusAvailable,
+		"192.168.3.1:30100": statusAvailable,
+	}
+	p.mutex.Unlock()
+	assert.Equal(t, "192.168.2.1:30100", p.GetAvailableAddress(), "same region should take precedence over diff az")
+	p.mutex.RLock()
+	tier, _ := p.getAvailableTier(ProtocolRest)
+	p.mutex.RUnlock()
+	assert.Equal(t, TierSameRegion, tier)
+}
+
+func TestPool_pickTier_spillover(t *testing.T) {
+	sameAz := []string{"127.0.0.1:30100", "127.0.0.2:30100", "127.0.0.3:30100", "127.0.0.4:30100"}
+	diffAz := "127.0.0.5:30100"
+	newPool := func(threshold float64) *Pool {
+		p := NewPool(nil, Options{SpilloverThreshold: threshold})
+		p.sameAzAddress[ProtocolRest] = sameAz
+		p.diffAzAddress[ProtocolRest] = []string{diffAz}
+		p.status[sameAz[0]] = statusAvailable
+		p.status[diffAz] = statusAvailable
+		return p
+	}
+
+	t.Run("disabled by default, should always pick the first healthy tier", func(t *testing.T) {
+		p := newPool(0)
+		defer p.Close()
+		for i := 0; i < 100; i++ {
+			tier, _ := p.pickTier(ProtocolRest)
+			assert.Equal(t, TierSameAz, tier)
+		}
+	})
+	t.Run("25% healthy with threshold 0.5, should spill half of the traffic", func(t *testing.T) {
+		p := newPool(0.5)
+		defer p.Close()
+		spilled := 0
+		for i := 0; i < 1000; i++ {
+			tier, addrs := p.pickTier(ProtocolRest)
+			if tier == TierDiffAz {
+				assert.Equal(t, []string{diffAz}, addrs)
+				spilled++
+			}
+		}
+		assert.InDelta(t, 500, spilled, 100)
+	})
+	t.Run("healthy ratio above threshold, should not spill", func(t *testing.T) {
+		p := newPool(0.2)
+		defer p.Close()
+		for i := 0; i < 100; i++ {
+			tier, _ := p.pickTier(ProtocolRest)
+			assert.Equal(t, TierSameAz, tier)
+		}
+	})
+	t.Run("the last healthy tier should take the rest of traffic", func(t *testing.T) {
+		p := newPool(1)
+		defer p.Close()
+		p.status[diffAz] = statusUnavailable
+		for i := 0; i < 100; i++ {
+			tier, _ := p.pickTier(ProtocolRest)
+			assert.Equal(t, TierSameAz, tier)
+		}
+	})
+}
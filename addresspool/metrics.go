@@ -0,0 +1,52 @@
+package addresspool
+
+import "time"
+
+// Metrics receives the measurements of the pool, e.g. to export them to prometheus,
+// the methods are called concurrently and should not block
+type Metrics interface {
+	// ObserveProbe is called after each active probe of an endpoint, err is nil if it is available
+	ObserveProbe(protocol, addr string, latency time.Duration, err error)
+	// SetEndpointStatus is called when the status of an endpoint is changed, by the active probe or the outlier detection
+	SetEndpointStatus(addr string, available bool)
+	// DeleteEndpoint is called when an endpoint is removed from the pool
+	DeleteEndpoint(addr string)
+	// SetTier is called when the tier in use of the protocol is changed
+	SetTier(protocol string, tier Tier)
+	// IncPick is called each time an address is picked, tier is TierNone if the default addresses are used as is
+	IncPick(protocol string, tier Tier, addr string)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveProbe(string, string, time.Duration, error) {}
+func (noopMetrics) SetEndpointStatus(string, bool)                    {}
+func (noopMetrics) DeleteEndpoint(string)                             {}
+func (noopMetrics) SetTier(string, Tier)                              {}
+func (noopMetrics) IncPick(string, Tier, string)                      {}
+
+// recordState reports the changes from prev to cur to the metrics, all of cur is reported if prev is nil
+func (p *Pool) recordState(prev, cur *poolState) {
+	for addr, available := range cur.available {
+		if prev == nil {
+			p.metrics.SetEndpointStatus(addr, available)
+			continue
+		}
+		if prevAvailable, ok := prev.available[addr]; !ok || prevAvailable != available {
+			p.metrics.SetEndpointStatus(addr, available)
+		}
+	}
+	for protocol, tier := range cur.tiers {
+		if prev == nil || prev.tiers[protocol] != tier {
+			p.metrics.SetTier(protocol, tier)
+		}
+	}
+	if prev == nil {
+		return
+	}
+	for addr := range prev.available {
+		if _, ok := cur.available[addr]; !ok {
+			p.metrics.DeleteEndpoint(addr)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package addresspool
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/openlog"
+)
+
+const (
+	defaultConsecutiveFailures = 5
+	defaultMinRequests         = 10
+	defaultOutlierInterval     = 10 * time.Second
+	defaultBaseEjectionTime    = 10 * time.Second
+	defaultMaxEjectionTime     = 5 * time.Minute
+)
+
+// OutlierDetectionOptions configures the passive outlier detection based on the results reported by callers.
+// An endpoint is ejected after ConsecutiveFailures failures in a row, or when the error rate of
+// at least MinRequests calls in Interval reaches ErrorRateThreshold.
+// The ejection time starts from BaseEjectionTime and doubles on each ejection in a row, up to MaxEjectionTime,
+// after that the endpoint is re-admitted half-open: it is available for all the calls at once,
+// the first result reported closes it on success, or ejects it again on failure
+type OutlierDetectionOptions struct {
+	ConsecutiveFailures int           // default 5
+	ErrorRateThreshold  float64       // in (0, 1], error rate check is disabled if not set
+	MinRequests         int           // default 10
+	Interval            time.Duration // default 10s
+	SlowCallThreshold   time.Duration // a call slower than it is counted as a failure, disabled if not set
+	BaseEjectionTime    time.Duration // default 10s
+	MaxEjectionTime     time.Duration // default 5m
+}
+
+type outlierState struct {
+	consecutiveFailures int
+	windowStart         time.Time
+	requests            int
+	failures            int
+
+	ejections    int // ejections in a row, drives the back-off
+	ejectedUntil time.Time
+	halfOpen     bool
+}
+
+type outlierDetector struct {
+	mutex  sync.Mutex
+	opts   OutlierDetectionOptions
+	states map[string]*outlierState
+}
+
+func newOutlierDetector(opts OutlierDetectionOptions) *outlierDetector {
+	if opts.ConsecutiveFailures <= 0 {
+		opts.ConsecutiveFailures = defaultConsecutiveFailures
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = defaultMinRequests
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultOutlierInterval
+	}
+	if opts.BaseEjectionTime <= 0 {
+		opts.BaseEjectionTime = defaultBaseEjectionTime
+	}
+	if opts.MaxEjectionTime < opts.BaseEjectionTime {
+		opts.MaxEjectionTime = defaultMaxEjectionTime
+		if opts.MaxEjectionTime < opts.BaseEjectionTime {
+			opts.MaxEjectionTime = opts.BaseEjectionTime
+		}
+	}
+	return &outlierDetector{
+		opts:   opts,
+		states: make(map[string]*outlierState),
+	}
+}
+
+// report records the result of a call, returns the ejection time if addr is ejected by this call, otherwise 0
+func (d *outlierDetector) report(addr string, err error, latency time.Duration, now time.Time) time.Duration {
+	failed := err != nil || (d.opts.SlowCallThreshold > 0 && latency > d.opts.SlowCallThreshold)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	s, ok := d.states[addr]
+	if !ok {
+		s = &outlierState{windowStart: now}
+		d.states[addr] = s
+	}
+
+	if !s.ejectedUntil.IsZero() {
+		if now.Before(s.ejectedUntil) {
+			return 0 // results of the calls started before the ejection
+		}
+		s.ejectedUntil = time.Time{}
+		s.halfOpen = true
+	}
+	if s.halfOpen {
+		if failed {
+			return d.eject(addr, s, now)
+		}
+		s.halfOpen = false
+		s.ejections = 0
+		s.resetCounters(now)
+		return 0
+	}
+
+	if now.Sub(s.windowStart) > d.opts.Interval {
+		s.windowStart = now
+		s.requests = 0
+		s.failures = 0
+	}
+	s.requests++
+	if !failed {
+		s.consecutiveFailures = 0
+		return 0
+	}
+	s.failures++
+	s.consecutiveFailures++
+
+	if s.consecutiveFailures >= d.opts.ConsecutiveFailures || d.errorRateExceeded(s) {
+		return d.eject(addr, s, now)
+	}
+	return 0
+}
+
+func (d *outlierDetector) errorRateExceeded(s *outlierState) bool {
+	if d.opts.ErrorRateThreshold <= 0 || s.requests < d.opts.MinRequests {
+		return false
+	}
+	return float64(s.failures)/float64(s.requests) >= d.opts.ErrorRateThreshold
+}
+
+func (d *outlierDetector) eject(addr string, s *outlierState, now time.Time) time.Duration {
+	s.ejections++
+	ejectionTime := d.opts.BaseEjectionTime
+	for n := 1; n < s.ejections && ejectionTime < d.opts.MaxEjectionTime; n++ {
+		ejectionTime *= 2
+	}
+	if ejectionTime > d.opts.MaxEjectionTime {
+		ejectionTime = d.opts.MaxEjectionTime
+	}
+	s.ejectedUntil = now.Add(ejectionTime)
+	s.halfOpen = false
+	s.resetCounters(now)
+	openlog.Warn(fmt.Sprintf("%s is ejected for %s, ejections in a row: %d", addr, ejectionTime, s.ejections))
+	return ejectionTime
+}
+
+func (s *outlierState) resetCounters(now time.Time) {
+	s.consecutiveFailures = 0
+	s.windowStart = now
+	s.requests = 0
+	s.failures = 0
+}
+
+// isEjected reports whether addr is ejected at now, it is safe to call on a nil detector
+func (d *outlierDetector) isEjected(addr string, now time.Time) bool {
+	if d == nil {
+		return false
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	s, ok := d.states[addr]
+	if !ok {
+		return false
+	}
+	return now.Before(s.ejectedUntil)
+}
+
+func (d *outlierDetector) reset() {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.states = make(map[string]*outlierState)
+}
@@ -0,0 +1,130 @@
+package addresspool
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errCall = errors.New("call failed")
+
+func TestOutlierDetector_consecutiveFailures(t *testing.T) {
+	d := newOutlierDetector(OutlierDetectionOptions{
+		ConsecutiveFailures: 3,
+		BaseEjectionTime:    time.Second,
+		MaxEjectionTime:     3 * time.Second,
+	})
+	addr := "127.0.0.1:30100"
+	now := time.Now()
+
+	t.Run("a success between failures, should not be ejected", func(t *testing.T) {
+		assert.Zero(t, d.report(addr, errCall, 0, now))
+		assert.Zero(t, d.report(addr, errCall, 0, now))
+		assert.Zero(t, d.report(addr, nil, 0, now))
+		assert.Zero(t, d.report(addr, errCall, 0, now))
+		assert.Zero(t, d.report(addr, errCall, 0, now))
+		assert.False(t, d.isEjected(addr, now))
+	})
+	t.Run("failures in a row, should be ejected for base ejection time", func(t *testing.T) {
+		assert.NotZero(t, d.report(addr, errCall, 0, now))
+		assert.True(t, d.isEjected(addr, now))
+		assert.False(t, d.isEjected(addr, now.Add(time.Second)))
+	})
+	t.Run("half-open and failed again, ejection time should be doubled", func(t *testing.T) {
+		now = now.Add(time.Second)
+		assert.NotZero(t, d.report(addr, errCall, 0, now))
+		assert.True(t, d.isEjected(addr, now.Add(2*time.Second-time.Millisecond)))
+		assert.False(t, d.isEjected(addr, now.Add(2*time.Second)))
+	})
+	t.Run("ejection time should not exceed the max", func(t *testing.T) {
+		now = now.Add(2 * time.Second)
+		assert.NotZero(t, d.report(addr, errCall, 0, now))
+		assert.False(t, d.isEjected(addr, now.Add(3*time.Second)))
+	})
+	t.Run("half-open and succeeded, should be closed", func(t *testing.T) {
+		now = now.Add(3 * time.Second)
+		assert.Zero(t, d.report(addr, nil, 0, now))
+		assert.False(t, d.isEjected(addr, now))
+		assert.Zero(t, d.report(addr, errCall, 0, now))
+		assert.Zero(t, d.report(addr, errCall, 0, now))
+		assert.NotZero(t, d.report(addr, errCall, 0, now))
+		assert.False(t, d.isEjected(addr, now.Add(time.Second)), "back-off should be reset")
+	})
+}
+
+func TestOutlierDetector_errorRate(t *testing.T) {
+	d := newOutlierDetector(OutlierDetectionOptions{
+		ConsecutiveFailures: 100,
+		ErrorRateThreshold:  0.5,
+		MinRequests:         4,
+		Interval:            time.Minute,
+		SlowCallThreshold:   time.Second,
+	})
+	addr := "127.0.0.1:30100"
+	now := time.Now()
+
+	assert.Zero(t, d.report(addr, nil, 0, now))
+	assert.Zero(t, d.report(addr, errCall, 0, now))
+	assert.Zero(t, d.report(addr, nil, 0, now))
+	// a slow call is a failure
+	assert.NotZero(t, d.report(addr, nil, 2*time.Second, now))
+	assert.True(t, d.isEjected(addr, now))
+
+	t.Run("counters should be reset when interval passed", func(t *testing.T) {
+		addr := "127.0.0.1:30101"
+		assert.Zero(t, d.report(addr, errCall, 0, now))
+		assert.Zero(t, d.report(addr, errCall, 0, now))
+		later := now.Add(2 * time.Minute)
+		assert.Zero(t, d.report(addr, nil, 0, later))
+		assert.Zero(t, d.report(addr, nil, 0, later))
+		assert.Zero(t, d.report(addr, errCall, 0, later))
+		assert.Zero(t, d.report(addr, nil, 0, later))
+	})
+}
+
+func TestPool_ReportResult(t *testing.T) {
+	addr1 := "127.0.0.1:30101"
+	addr2 := "127.0.0.1:30102"
+
+	p := NewPool([]string{addr1, addr2})
+	p.status[addr1] = statusAvailable
+	p.status[addr2] = statusAvailable
+	for i := 0; i < 10; i++ {
+		p.ReportResult(addr1, errCall, 0)
+	}
+	assert.Equal(t, []string{addr1, addr2}, p.getAvailableAddressList(ProtocolRest), "detection is disabled by default")
+
+	p = NewPool([]string{addr1, addr2}, Options{OutlierDetection: &OutlierDetectionOptions{ConsecutiveFailures: 2}})
+	p.status[addr1] = statusAvailable
+	p.status[addr2] = statusAvailable
+	p.ReportResult(addr1, errCall, 0)
+	p.ReportResult(addr1, errCall, 0)
+	assert.Equal(t, []string{addr2}, p.getAvailableAddressList(ProtocolRest))
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, addr2, p.GetAvailableAddress())
+	}
+
+	p.ResetAddress([]string{addr1, addr2})
+	p.status[addr1] = statusAvailable
+	assert.Equal(t, []string{addr1}, p.getAvailableAddressList(ProtocolRest))
+}
+
+func TestPool_ReportResult_afterClose(t *testing.T) {
+	addr := "127.0.0.1:30101"
+	p := NewPool([]string{addr}, Options{OutlierDetection: &OutlierDetectionOptions{
+		ConsecutiveFailures: 1, BaseEjectionTime: 10 * time.Millisecond}})
+	p.status[addr] = statusAvailable
+	p.refreshState()
+	p.ReportResult(addr, errCall, 0)
+	p.stateMutex.Lock()
+	assert.False(t, p.state.available[addr])
+	p.stateMutex.Unlock()
+
+	p.Close()
+	time.Sleep(30 * time.Millisecond)
+	p.stateMutex.Lock()
+	assert.False(t, p.state.available[addr], "state should not be refreshed after close")
+	p.stateMutex.Unlock()
+}
@@ -7,12 +7,10 @@ import (
 	"io"
 	"net"
 	"net/http"
-	"os"
-	"strconv"
+	"sort"
 	"sync"
 	"time"
 
-	"github.com/go-chassis/foundation/httpclient"
 	"github.com/go-chassis/openlog"
 
 	"github.com/go-chassis/cari/discovery"
@@ -21,6 +19,12 @@ import (
 // EnvCheckScInterval sc instance health check interval in second
 const EnvCheckScInterval = "CHASSIS_SC_HEALTH_CHECK_INTERVAL"
 
+const (
+	// ProtocolRest is the protocol of the default addresses, it is used by GetAvailableAddress
+	ProtocolRest = "rest"
+	ProtocolGrpc = "grpc"
+)
+
 const (
 	statusAvailable   string = "available"
 	statusUnavailable string = "unavailable"
@@ -38,99 +42,280 @@ const (
 type HttpProbeOptions struct {
 	Protocol string
 	Path     string
+	// TLSConfig is used by https probe, the server certificate is verified by the system roots if not set
+	TLSConfig *tls.Config
+	// InsecureSkipVerify skips verifying the server certificate of https probe when TLSConfig is not set,
+	// only for the servers with self-signed certificates
+	InsecureSkipVerify bool
+	// Headers are added to each probe request, e.g. Authorization header with a rbac token
+	Headers http.Header
+	// SignRequest is called before each probe request is sent, it can set the headers changing over time
+	SignRequest func(*http.Request) error
+	// Method default GET
+	Method string
+	// ExpectedStatus the status codes of a healthy endpoint, default 2xx
+	ExpectedStatus []int
+	// BodyMatcher checks the response body of the expected status, the endpoint is unavailable if it returns false
+	BodyMatcher func(body []byte) bool
+	// DisableTCPFallback by default, tcp probe is used when the api returns 404, to compatible with the old servers
+	DisableTCPFallback bool
+}
+
+func (o *HttpProbeOptions) method() string {
+	if len(o.Method) == 0 {
+		return http.MethodGet
+	}
+	return o.Method
+}
+
+func (o *HttpProbeOptions) isExpectedStatus(code int) bool {
+	if len(o.ExpectedStatus) == 0 {
+		return code >= http.StatusOK && code < http.StatusMultipleChoices
+	}
+	for _, expected := range o.ExpectedStatus {
+		if code == expected {
+			return true
+		}
+	}
+	return false
 }
 
 type Options struct {
-	HttpProbeOptions *HttpProbeOptions // used in available check if set, tcp will be used if not set
-	DiffAzEndpoints  []string
+	HttpProbeOptions *HttpProbeOptions // used to check the addresses of ProtocolRest if set, tcp will be used if not set
+	GrpcProbeOptions *GrpcProbeOptions // used to check the addresses of ProtocolGrpc if set, tcp will be used if not set
+	DiffAzEndpoints  []string          // addresses of ProtocolRest
+	Strategy         Strategy          // used to pick an available address, round-robin will be used if not set
+	// used to eject endpoints by the results reported through Pool.ReportResult, disabled if not set
+	OutlierDetection *OutlierDetectionOptions
+	ProbeOptions     *ProbeOptions // schedule of the active health probe, defaults are used if not set
+	// Locality is the region and az of the pool user, the instances are put in the tiers by their DataCenterInfo
+	// if set, otherwise the instances in the az of the default addresses are in the same az tier
+	Locality *Locality
+	// SpilloverThreshold in (0, 1], when the healthy ratio of a tier is below it, part of the traffic spills over
+	// to the next tiers in proportion, e.g. 0.7 with 35% healthy spills half of the traffic. Disabled if not set
+	SpilloverThreshold float64
+	// EndpointSource is polled by RefreshInterval to set the addresses by the instances, as SetAddressByInstances
+	EndpointSource  EndpointSource
+	RefreshInterval time.Duration    // default 30s
+	Metrics         Metrics          // receives the measurements of the pool, nothing is recorded if not set
+	Affinity        *AffinityOptions // used by GetAddressFor, defaults are used if not set
 }
 
 // Pool cloud server address pool
 type Pool struct {
 	mutex          sync.RWMutex
 	defaultAddress []string
-	// used when the server has addresses of multiple az
-	// when we get available address, the priority is sameAzAddress > diffAzAddress > defaultAddress
-	sameAzAddress []string
-	diffAzAddress []string
+	// used when the server has addresses of multiple az, keyed by protocol
+	// when we get available address, the priority is sameAzAddress > sameRegionAddress > diffAzAddress > defaultAddress,
+	// defaultAddress is of ProtocolRest only
+	sameAzAddress     map[string][]string
+	sameRegionAddress map[string][]string
+	diffAzAddress     map[string][]string
+
+	// diffAzEndpoints is Options.DiffAzEndpoints, used as the diff az addresses of ProtocolRest if no instance is in diff az
+	diffAzEndpoints []string
+
+	locality           *Locality
+	spilloverThreshold float64
 
 	status      map[string]string
 	onceMonitor sync.Once
 	quit        chan struct{}
 	onceQuit    sync.Once
-
-	httpProbeOptions *HttpProbeOptions
-	httpProbeClient  *httpclient.Requests
-	statusHistory    []map[string]string
+	// ctx is cancelled when the pool is closed, to stop the probes in flight
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	httpProbeOptions    *HttpProbeOptions
+	httpProbeClient     *http.Client
+	grpcProbeOptions    *GrpcProbeOptions
+	statusHistory       []map[string]string
+	probeOptions        ProbeOptions
+	probeOptionsChanged chan struct{}
+
+	endpointSource          EndpointSource
+	endpointRefreshInterval time.Duration
+	endpointsChanged        chan struct{}
+
+	strategy Strategy
+	outlier  *outlierDetector
+	affinity *affinity
+
+	metrics    Metrics
+	events     *eventBus
+	stateMutex sync.Mutex
+	state      *poolState
 }
 
 func (p *Pool) Close() {
 	p.onceQuit.Do(func() {
+		p.cancel()
 		close(p.quit)
+		p.affinity.stop()
+		if p.httpProbeClient != nil {
+			p.httpProbeClient.CloseIdleConnections()
+		}
 	})
 }
 
 // NewPool Get registry pool instance
 func NewPool(addresses []string, opts ...Options) *Pool {
 	p := &Pool{
-		defaultAddress: removeDuplicates(addresses),
-		status:         make(map[string]string),
-		statusHistory:  make([]map[string]string, 0, 4),
+		defaultAddress:    removeDuplicates(addresses),
+		sameAzAddress:     make(map[string][]string),
+		sameRegionAddress: make(map[string][]string),
+		diffAzAddress:     make(map[string][]string),
+		status:            make(map[string]string),
+		statusHistory:     make([]map[string]string, 0, 4),
+		strategy:          NewRoundRobinStrategy(),
+		metrics:           noopMetrics{},
+		events:            newEventBus(),
+
+		probeOptionsChanged: make(chan struct{}, 1),
+		endpointsChanged:    make(chan struct{}, 1),
 	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	affinityOpts := AffinityOptions{}
+	if len(opts) > 0 && opts[0].Affinity != nil {
+		affinityOpts = *opts[0].Affinity
+	}
+	p.affinity = newAffinity(affinityOpts)
 
 	if len(opts) > 0 {
 		if opts[0].HttpProbeOptions != nil {
 			optCopy := *(opts[0].HttpProbeOptions)
+			optCopy.Headers = optCopy.Headers.Clone()
 			p.httpProbeOptions = &optCopy
 			if len(p.httpProbeOptions.Protocol) == 0 {
 				p.httpProbeOptions.Protocol = "http"
 			}
-			p.httpProbeClient, _ = httpclient.New(&httpclient.Options{
-				TLSConfig:      &tls.Config{InsecureSkipVerify: true},
-				RequestTimeout: 5 * time.Second,
-			})
+			tlsConfig := p.httpProbeOptions.TLSConfig
+			if tlsConfig == nil && p.httpProbeOptions.InsecureSkipVerify {
+				if p.httpProbeOptions.Protocol == "https" {
+					openlog.Warn("https probe skips verifying the server certificate, set HttpProbeOptions.TLSConfig to verify it")
+				}
+				tlsConfig = &tls.Config{InsecureSkipVerify: true}
+			}
+			// the probe connections are kept apart from http.DefaultTransport and closed with the pool,
+			// each probe is bounded by its own context instead of a client timeout
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.Proxy = nil // the endpoints are probed directly, not through the proxy of the environment
+			transport.TLSClientConfig = tlsConfig
+			p.httpProbeClient = &http.Client{Transport: transport}
+		}
+		if opts[0].GrpcProbeOptions != nil {
+			optCopy := *(opts[0].GrpcProbeOptions)
+			optCopy.Metadata = make(map[string]string, len(opts[0].GrpcProbeOptions.Metadata))
+			for k, v := range opts[0].GrpcProbeOptions.Metadata {
+				optCopy.Metadata[k] = v
+			}
+			p.grpcProbeOptions = &optCopy
 		}
 		if len(opts[0].DiffAzEndpoints) != 0 {
-			p.diffAzAddress = opts[0].DiffAzEndpoints
+			p.diffAzEndpoints = opts[0].DiffAzEndpoints
+			p.diffAzAddress[ProtocolRest] = p.diffAzEndpoints
+		}
+		if opts[0].Strategy != nil {
+			p.strategy = opts[0].Strategy
+		}
+		if opts[0].OutlierDetection != nil {
+			p.outlier = newOutlierDetector(*opts[0].OutlierDetection)
+		}
+		if opts[0].ProbeOptions != nil {
+			p.probeOptions = *opts[0].ProbeOptions
+		}
+		if opts[0].Locality != nil {
+			locality := *opts[0].Locality
+			p.locality = &locality
+		}
+		p.spilloverThreshold = opts[0].SpilloverThreshold
+		p.endpointSource = opts[0].EndpointSource
+		p.endpointRefreshInterval = opts[0].RefreshInterval
+		if opts[0].Metrics != nil {
+			p.metrics = opts[0].Metrics
 		}
 	}
-	
+
 	p.monitor()
+	p.startRefresh()
 	return p
 }
 
+// ResetAddress replaces all the addresses, the key affinity of GetAddressFor is kept for the addresses still in the pool
 func (p *Pool) ResetAddress(addresses []string) {
 	p.mutex.Lock()
-	defer p.mutex.Unlock()
 	p.defaultAddress = removeDuplicates(addresses)
-	p.diffAzAddress = []string{}
-	p.sameAzAddress = []string{}
+	p.diffAzAddress = make(map[string][]string)
+	p.sameRegionAddress = make(map[string][]string)
+	p.sameAzAddress = make(map[string][]string)
 	p.status = make(map[string]string)
 	p.statusHistory = make([]map[string]string, 0, 4)
+	p.outlier.reset()
+	p.mutex.Unlock()
+
+	p.refreshState()
 }
 
+// SetAddressByInstances replaces the addresses of the tiers by the instances, Options.DiffAzEndpoints are
+// used if no diff az address of ProtocolRest. The addresses are kept as is if no instance has endpoints
 func (p *Pool) SetAddressByInstances(instances []*discovery.MicroServiceInstance) error {
+	if err := p.setAddressByInstances(instances); err != nil {
+		return err
+	}
+	p.refreshState()
+	return nil
+}
+
+func (p *Pool) setAddressByInstances(instances []*discovery.MicroServiceInstance) error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
-	azAddrMap := getAzAddressMap(instances)
-	if len(azAddrMap) == 0 {
+	groups := getAzGroups(instances)
+	if len(groups) == 0 {
 		return fmt.Errorf("sync endpoints failed")
 	}
 
-	for _, addrList := range azAddrMap {
-		uniqueAddrList := removeDuplicates(addrList)
-		if p.isSameAzAddr(uniqueAddrList) {
-			p.sameAzAddress = uniqueAddrList
-			openlog.Info(fmt.Sprintf("sync same az endpoints: %s", uniqueAddrList))
-			continue
+	tierAddress := map[Tier]map[string][]string{
+		TierSameAz:     make(map[string][]string),
+		TierSameRegion: make(map[string][]string),
+		TierDiffAz:     make(map[string][]string),
+	}
+	for _, g := range groups {
+		target := tierAddress[p.classify(g)]
+		for protocol, addrList := range g.addrs {
+			target[protocol] = append(target[protocol], addrList...)
 		}
-		p.diffAzAddress = uniqueAddrList
-		openlog.Info(fmt.Sprintf("sync different az endpoints: %s", addrList))
+	}
+	// the tiers are rebuilt, so the deregistered addresses are removed
+	p.sameAzAddress = dedupAddress(tierAddress[TierSameAz])
+	p.sameRegionAddress = dedupAddress(tierAddress[TierSameRegion])
+	p.diffAzAddress = dedupAddress(tierAddress[TierDiffAz])
+	if len(p.diffAzAddress[ProtocolRest]) == 0 && len(p.diffAzEndpoints) > 0 {
+		p.diffAzAddress[ProtocolRest] = p.diffAzEndpoints
+	}
+	for protocol, addrList := range p.sameAzAddress {
+		openlog.Info(fmt.Sprintf("sync same az %s endpoints: %s", protocol, addrList))
+	}
+	for protocol, addrList := range p.sameRegionAddress {
+		openlog.Info(fmt.Sprintf("sync same region %s endpoints: %s", protocol, addrList))
+	}
+	for protocol, addrList := range p.diffAzAddress {
+		openlog.Info(fmt.Sprintf("sync different az %s endpoints: %s", protocol, addrList))
 	}
 	return nil
 }
 
+// isSameAzGroup reports whether any address of the group is one of the default addresses
+func (p *Pool) isSameAzGroup(g *azGroup) bool {
+	for _, addrList := range g.addrs {
+		if p.isSameAzAddr(addrList) {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *Pool) isSameAzAddr(addrList []string) bool {
 	defaultAddrMap := make(map[string]struct{}, len(p.defaultAddress))
 	for _, addr := range p.defaultAddress {
@@ -144,44 +329,112 @@ func (p *Pool) isSameAzAddr(addrList []string) bool {
 	return false
 }
 
-// GetAvailableAddress Get an available address from pool by roundrobin
+// GetAvailableAddress Get an available address of ProtocolRest from pool by the strategy, roundrobin by default
 func (p *Pool) GetAvailableAddress() string {
+	return p.getAvailableAddress(ProtocolRest, "")
+}
+
+// GetAvailableAddressByKey Get an available address of ProtocolRest from pool by the strategy,
+// the key is passed to the strategy, e.g. consistent hash strategy maps it to an address
+func (p *Pool) GetAvailableAddressByKey(key string) string {
+	return p.getAvailableAddress(ProtocolRest, key)
+}
+
+// GetAvailableAddressFor Get an available address of the protocol from pool by the strategy,
+// the protocol is the scheme of the instance endpoints, e.g. rest, grpc, highway
+func (p *Pool) GetAvailableAddressFor(protocol string) string {
+	return p.getAvailableAddress(protocol, "")
+}
+
+func (p *Pool) getAvailableAddress(protocol, key string) string {
 	p.mutex.RLock()
 	defer p.mutex.RUnlock()
-	addrs := p.getAvailableAddressList()
-	if len(addrs) == 0 {
+	tier, addrs := p.pickTier(protocol)
+	if len(addrs) == 0 && protocol == ProtocolRest {
 		addrs = p.defaultAddress
 	}
 
-	next := RoundRobin(addrs)
-	addr, err := next()
+	addr, err := p.strategy.Pick(addrs, key)
 	if err != nil {
 		return ""
 	}
+	p.metrics.IncPick(protocol, tier, addr)
 	return addr
 }
 
-func (p *Pool) getAvailableAddressList() []string {
-	if addrs := p.filterAvailableAddress(p.sameAzAddress); len(addrs) > 0 {
-		return addrs
+// ReportResult reports the result of a call to addr, it drives the passive outlier detection,
+// the ejected address is not available until it is re-admitted even if the active probe succeeds.
+// The re-admitted address takes all its traffic at once, there is no limit of the trial calls,
+// the first result reported after the re-admission closes it or ejects it again.
+// It does nothing if Options.OutlierDetection is not set
+func (p *Pool) ReportResult(addr string, err error, latency time.Duration) {
+	if p.outlier == nil {
+		return
 	}
-	if addrs := p.filterAvailableAddress(p.diffAzAddress); len(addrs) > 0 {
-		return addrs
+	ejectionTime := p.outlier.report(addr, err, latency, time.Now())
+	if ejectionTime == 0 {
+		return
 	}
-	if addrs := p.filterAvailableAddress(p.defaultAddress); len(addrs) > 0 {
-		return addrs
+	p.refreshState()
+	// the address is re-admitted after the ejection time, nothing is done if the pool is closed by then
+	time.AfterFunc(ejectionTime, p.refreshState)
+}
+
+// Subscribe registers a handler of the pool events, the handlers are called in a separate goroutine
+// one by one in the order of the events, so a handler should not block.
+// It returns a function to cancel the subscription
+func (p *Pool) Subscribe(handler func(Event)) func() {
+	return p.events.subscribe(handler)
+}
+
+// Done tells the strategy that the call to addr is finished,
+// it is required by the strategies which track calls in flight, e.g. least in-flight strategy
+func (p *Pool) Done(addr string) {
+	if t, ok := p.strategy.(InFlightTracker); ok {
+		t.Done(addr)
 	}
+}
 
-	return nil
+func (p *Pool) getAvailableAddressList(protocol string) []string {
+	_, addrs := p.getAvailableTier(protocol)
+	return addrs
+}
+
+// getAvailableTier returns the first tier with available addresses, it ignores the spill-over
+func (p *Pool) getAvailableTier(protocol string) (Tier, []string) {
+	for _, t := range p.tiers(protocol) {
+		if addrs := p.filterAvailableAddress(t.addrs); len(addrs) > 0 {
+			return t.tier, addrs
+		}
+	}
+	return TierNone, nil
+}
+
+// protocols returns all the protocols of the pool, ProtocolRest is always included
+func (p *Pool) protocols() []string {
+	protocols := []string{ProtocolRest}
+	seen := map[string]struct{}{ProtocolRest: {}}
+	for _, m := range []map[string][]string{p.sameAzAddress, p.sameRegionAddress, p.diffAzAddress} {
+		for protocol := range m {
+			if _, ok := seen[protocol]; ok {
+				continue
+			}
+			seen[protocol] = struct{}{}
+			protocols = append(protocols, protocol)
+		}
+	}
+	sort.Strings(protocols[1:])
+	return protocols
 }
 
 func (p *Pool) filterAvailableAddress(addresses []string) []string {
 	if len(addresses) == 0 {
 		return nil
 	}
+	now := time.Now()
 	result := make([]string, 0)
 	for _, v := range addresses {
-		if p.status[v] == statusAvailable {
+		if p.status[v] == statusAvailable && !p.outlier.isEjected(v, now) {
 			result = append(result, v)
 		}
 	}
@@ -191,9 +444,14 @@ func (p *Pool) filterAvailableAddress(addresses []string) []string {
 func (p *Pool) CheckReadiness() int {
 	p.mutex.RLock()
 	defer p.mutex.RUnlock()
+	return p.readiness()
+}
+
+func (p *Pool) readiness() int {
 	statusHistory := p.statusHistory
+	successThreshold, failureThreshold := p.readinessThresholds()
 
-	if len(statusHistory) < 2 {
+	if len(statusHistory) < successThreshold && len(statusHistory) < failureThreshold {
 		return ReadinessIndeterminate
 	}
 
@@ -208,16 +466,55 @@ func (p *Pool) CheckReadiness() int {
 		successCnt++
 		failedCnt = 0
 	}
-	if successCnt >= 2 {
+	if successCnt >= successThreshold {
 		return ReadinessSuccess
 	}
-	if failedCnt == 3 {
+	if failedCnt >= failureThreshold {
 		return ReadinessFailed
 	}
 
 	return ReadinessIndeterminate
 }
 
+// refreshState compares the pool with the last snapshot, and publishes the transitions
+func (p *Pool) refreshState() {
+	if p.ctx.Err() != nil {
+		return // closed, no one receives the events
+	}
+	p.stateMutex.Lock()
+	defer p.stateMutex.Unlock()
+
+	p.mutex.RLock()
+	now := time.Now()
+	cur := &poolState{
+		available: make(map[string]bool, len(p.status)),
+		tiers:     make(map[string]Tier),
+	}
+	for _, target := range p.targets() {
+		status, ok := p.status[target.addr]
+		if !ok {
+			// not probed yet, e.g. just added, keep the last known status
+			if p.state != nil {
+				if available, exist := p.state.available[target.addr]; exist {
+					cur.available[target.addr] = available
+				}
+			}
+			continue
+		}
+		cur.available[target.addr] = status == statusAvailable && !p.outlier.isEjected(target.addr, now)
+	}
+	for _, protocol := range p.protocols() {
+		cur.tiers[protocol], _ = p.getAvailableTier(protocol)
+	}
+	cur.readiness = p.readiness()
+	p.mutex.RUnlock()
+
+	events := diffState(p.state, cur, now)
+	p.recordState(p.state, cur)
+	p.state = cur
+	p.events.publish(events...)
+}
+
 func existAvailableEndpointInStatus(status map[string]string) bool {
 	for _, v := range status {
 		if v == statusAvailable {
@@ -227,46 +524,124 @@ func existAvailableEndpointInStatus(status map[string]string) bool {
 	return false
 }
 
+// probeTarget is an address to probe and its protocol
+type probeTarget struct {
+	protocol string
+	addr     string
+}
+
+func (p *Pool) probeTargets() []probeTarget {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.targets()
+}
+
+// targets returns all the addresses in the pool, the caller must hold the mutex
+func (p *Pool) targets() []probeTarget {
+	targets := make([]probeTarget, 0, len(p.defaultAddress))
+	seen := make(map[string]struct{})
+	add := func(protocol string, addrs []string) {
+		for _, addr := range addrs {
+			if _, exist := seen[addr]; exist {
+				continue
+			}
+			seen[addr] = struct{}{}
+			targets = append(targets, probeTarget{protocol: protocol, addr: addr})
+		}
+	}
+	add(ProtocolRest, p.defaultAddress)
+	for _, protocol := range p.protocols() {
+		add(protocol, p.sameAzAddress[protocol])
+		add(protocol, p.sameRegionAddress[protocol])
+		add(protocol, p.diffAzAddress[protocol])
+	}
+	return targets
+}
+
 func (p *Pool) checkConnectivity() {
-	toCheckedAddressList := make([]string, 0, len(p.defaultAddress)+len(p.sameAzAddress)+len(p.diffAzAddress))
-	toCheckedAddressList = append(toCheckedAddressList, p.defaultAddress...)
-	toCheckedAddressList = append(toCheckedAddressList, p.sameAzAddress...)
-	toCheckedAddressList = append(toCheckedAddressList, p.diffAzAddress...)
+	targets := p.probeTargets()
+
+	ctx, cancel := context.WithTimeout(p.ctx, p.probeRoundTimeout())
+	defer cancel()
+	errs := p.probeAll(ctx, targets)
+	if p.ctx.Err() != nil {
+		return // closed, the results are meaningless
+	}
 
 	status := make(map[string]string) // create new map, to clear dirty address
-	for _, v := range toCheckedAddressList {
-		if _, exist := status[v]; exist {
-			continue
-		}
-		err := p.doCheckConnectivity(v)
-		if err != nil {
-			openlog.Error(fmt.Sprintf("%s connectivity unavailable: %s", v, err))
-			status[v] = statusUnavailable
+	for i, target := range targets {
+		if errs[i] != nil {
+			openlog.Error(fmt.Sprintf("%s connectivity unavailable: %s", target.addr, errs[i]))
+			status[target.addr] = statusUnavailable
 		} else {
-			status[v] = statusAvailable
+			status[target.addr] = statusAvailable
 		}
 	}
 
 	p.mutex.Lock()
 	p.status = status
 	p.statusHistory = append(p.statusHistory, status)
-	cnt := len(p.statusHistory)
-	if cnt > 3 {
-		p.statusHistory = p.statusHistory[(cnt - 3):]
-	}
+	p.trimStatusHistory()
 	p.mutex.Unlock()
+
+	p.refreshState()
 }
 
-func (p *Pool) doCheckConnectivity(endpoint string) error {
+// probeAll probes the targets by a bounded count of workers, the errors are in the order of targets
+func (p *Pool) probeAll(ctx context.Context, targets []probeTarget) []error {
+	errs := make([]error, len(targets))
+	workers := p.probeConcurrency()
+	if workers > len(targets) {
+		workers = len(targets)
+	}
+
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				start := time.Now()
+				errs[i] = p.doCheckConnectivityFor(ctx, targets[i].protocol, targets[i].addr)
+				p.metrics.ObserveProbe(targets[i].protocol, targets[i].addr, time.Since(start), errs[i])
+			}
+		}()
+	}
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return errs
+}
+
+// doCheckConnectivityFor probes the endpoint by the probe of its protocol, the rest addresses use
+// the http probe if set, the grpc addresses use the grpc probe if set, the others use tcp
+func (p *Pool) doCheckConnectivityFor(ctx context.Context, protocol, endpoint string) error {
+	switch protocol {
+	case ProtocolRest:
+		return p.doCheckConnectivity(ctx, endpoint)
+	case ProtocolGrpc:
+		if p.grpcProbeOptions != nil {
+			return p.doCheckConnectivityWithGrpc(ctx, endpoint)
+		}
+	}
+	return p.doCheckConnectivityWithTcp(ctx, endpoint)
+}
+
+// doCheckConnectivity probes the endpoint of ProtocolRest, by http if set, otherwise by tcp
+func (p *Pool) doCheckConnectivity(ctx context.Context, endpoint string) error {
 	if p.httpProbeOptions != nil {
-		return p.doCheckConnectivityWithHttp(endpoint)
+		return p.doCheckConnectivityWithHttp(ctx, endpoint)
 	}
 
-	return p.doCheckConnectivityWithTcp(endpoint)
+	return p.doCheckConnectivityWithTcp(ctx, endpoint)
 }
 
-func (p *Pool) doCheckConnectivityWithTcp(endpoint string) error {
-	conn, err := net.DialTimeout("tcp", endpoint, healthProbeTimeout)
+func (p *Pool) doCheckConnectivityWithTcp(ctx context.Context, endpoint string) error {
+	dialer := &net.Dialer{Timeout: p.probeTimeout(healthProbeTimeout)}
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint)
 	if err != nil {
 		return err
 	}
@@ -279,60 +654,95 @@ func (p *Pool) doCheckConnectivityWithTcp(endpoint string) error {
 	return nil
 }
 
-func (p *Pool) doCheckConnectivityWithHttp(endpoint string) error {
+func (p *Pool) doCheckConnectivityWithHttp(ctx context.Context, endpoint string) error {
 	u := p.httpProbeOptions.Protocol + "://" + endpoint + p.httpProbeOptions.Path
-	resp, err := p.httpProbeClient.Get(context.Background(), u, nil)
+	ctx, cancel := context.WithTimeout(ctx, p.probeTimeout(httpProbeTimeout))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, p.httpProbeOptions.method(), u, nil)
+	if err != nil {
+		return fmt.Errorf("create request failed: %s", err)
+	}
+	if p.httpProbeOptions.Headers != nil {
+		req.Header = p.httpProbeOptions.Headers.Clone()
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "*/*")
+	}
+	if p.httpProbeOptions.SignRequest != nil {
+		if err := p.httpProbeOptions.SignRequest(req); err != nil {
+			return fmt.Errorf("sign request failed: %s", err)
+		}
+	}
+	resp, err := p.httpProbeClient.Do(req)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		// drain the rest of body, so that the connection can be reused by the next probe
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxProbeBodySize))
+		err := resp.Body.Close()
+		if err != nil {
+			openlog.Error(fmt.Sprintf("close http resp.Body failed when check connectivity: %s", err))
+		}
+	}()
 
-	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+	expected := p.httpProbeOptions.isExpectedStatus(resp.StatusCode)
+	if expected && p.httpProbeOptions.BodyMatcher == nil {
 		return nil
 	}
 	// do tcp check if api not exist, ensure to compatible with old scenes
-	if resp.StatusCode == http.StatusNotFound {
-		return p.doCheckConnectivityWithTcp(endpoint)
+	if !expected && resp.StatusCode == http.StatusNotFound && !p.httpProbeOptions.DisableTCPFallback {
+		return p.doCheckConnectivityWithTcp(ctx, endpoint)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBodySize))
 	if err != nil {
 		return fmt.Errorf("http status: %s, read resp error: %s", resp.Status, err)
 	}
-	err = resp.Body.Close()
-	if err != nil {
-		openlog.Error(fmt.Sprintf("close http resp.Body failed when check connectivity: %s", err))
+	if !expected {
+		return fmt.Errorf("http status: %s, resp: %s", resp.Status, string(body))
 	}
-	return fmt.Errorf("http status: %s, resp: %s", resp.Status, string(body))
+	if !p.httpProbeOptions.BodyMatcher(body) {
+		return fmt.Errorf("http status: %s, resp mismatched: %s", resp.Status, string(body))
+	}
+	return nil
 }
 
 func (p *Pool) monitor() {
 	p.onceMonitor.Do(func() {
-		var interval time.Duration
-		v, isExist := os.LookupEnv(EnvCheckScInterval)
-		if !isExist {
-			interval = defaultCheckScIntervalInSecond
-		} else {
-			i, err := strconv.ParseInt(v, 10, 64)
-			if err != nil {
-				interval = defaultCheckScIntervalInSecond
-			} else {
-				interval = time.Duration(i)
-			}
-		}
-		ticker := time.NewTicker(interval * time.Second)
 		p.quit = make(chan struct{})
+		p.events.start(p.quit)
 
 		p.checkConnectivity()
 		go func() {
+			timer := time.NewTimer(p.nextProbeDelay())
+			defer timer.Stop()
 			for {
 				select {
-				case <-ticker.C:
+				case <-timer.C:
+					p.checkConnectivity()
+				case <-p.endpointsChanged:
+					// probe the new addresses at once, then restart the schedule
 					p.checkConnectivity()
+					stopTimer(timer)
+				case <-p.probeOptionsChanged:
+					// reschedule the next round by the new interval
+					stopTimer(timer)
 				case <-p.quit:
-					ticker.Stop()
 					return
 				}
+				timer.Reset(p.nextProbeDelay())
 			}
 		}()
 	})
 }
+
+// stopTimer stops the timer and drains its channel, so it can be reset
+func stopTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+}
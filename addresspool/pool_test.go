@@ -1,11 +1,14 @@
 package addresspool
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"reflect"
-	"sync"
+	"strings"
 	"testing"
 	"time"
 
@@ -63,8 +66,8 @@ func TestAddressPool_GetAvailableAddress_priority(t *testing.T) {
 			name: "same az address available, return same az address",
 			preDo: func() {
 				p.defaultAddress = []string{defaultAddr}
-				p.sameAzAddress = []string{sameAzAddr}
-				p.diffAzAddress = []string{diffAzAddr}
+				p.sameAzAddress[ProtocolRest] = []string{sameAzAddr}
+				p.diffAzAddress[ProtocolRest] = []string{diffAzAddr}
 				p.status[sameAzAddr] = statusAvailable
 				p.status[diffAzAddr] = statusAvailable
 				p.status[defaultAddr] = statusAvailable
@@ -123,6 +126,28 @@ func TestAddressPool_GetAvailableAddress_filter(t *testing.T) {
 	assert.NotEqual(t, p.GetAvailableAddress(), p.GetAvailableAddress())
 }
 
+func TestAddressPool_GetAvailableAddress_strategy(t *testing.T) {
+	addr1 := "127.0.0.1:30101"
+	addr2 := "127.0.0.1:30102"
+	p := NewPool([]string{addr1, addr2}, Options{Strategy: NewLeastInFlightStrategy()})
+	p.status[addr1] = statusAvailable
+	p.status[addr2] = statusAvailable
+
+	first := p.GetAvailableAddress()
+	second := p.GetAvailableAddress()
+	assert.NotEqual(t, first, second)
+	p.Done(first)
+	assert.Equal(t, first, p.GetAvailableAddress())
+
+	p = NewPool([]string{addr1, addr2}, Options{Strategy: NewConsistentHashStrategy(0)})
+	p.status[addr1] = statusAvailable
+	p.status[addr2] = statusAvailable
+	addr := p.GetAvailableAddressByKey("key")
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, addr, p.GetAvailableAddressByKey("key"))
+	}
+}
+
 func TestAddressPool_SetAddressByInstances(t *testing.T) {
 	p := NewPool([]string{"192.168.2.1:30100", "192.168.2.3:30100"}) // default address is of az2
 
@@ -164,8 +189,8 @@ func TestAddressPool_SetAddressByInstances(t *testing.T) {
 	}
 	err := p.SetAddressByInstances(instances)
 	assert.NoError(t, err)
-	assert.Equal(t, []string{"192.168.2.1:30100", "192.168.2.2:30100"}, p.sameAzAddress)
-	assert.Equal(t, []string{"192.168.1.1:30100", "192.168.1.2:30100"}, p.diffAzAddress)
+	assert.Equal(t, []string{"192.168.2.1:30100", "192.168.2.2:30100"}, p.sameAzAddress[ProtocolRest])
+	assert.Equal(t, []string{"192.168.1.1:30100", "192.168.1.2:30100"}, p.diffAzAddress[ProtocolRest])
 }
 
 // pool 存在diffAzAddress时，通过SetAddressByInstances方法后，diffAzAddress不会为空
@@ -174,11 +199,11 @@ func TestAddressPool_SetAddressByInstancesForDiffAzAddress(t *testing.T) {
 		DiffAzEndpoints: []string{"192.168.3.5:30100"}})
 	err := p.SetAddressByInstances(nil)
 	assert.Error(t, err)
-	assert.Equal(t, []string{"192.168.3.5:30100"}, p.diffAzAddress)
+	assert.Equal(t, []string{"192.168.3.5:30100"}, p.diffAzAddress[ProtocolRest])
 
 	err = p.SetAddressByInstances([]*discovery.MicroServiceInstance{})
 	assert.Error(t, err)
-	assert.Equal(t, []string{"192.168.3.5:30100"}, p.diffAzAddress)
+	assert.Equal(t, []string{"192.168.3.5:30100"}, p.diffAzAddress[ProtocolRest])
 
 	// SetAddressByInstances仅传入本端地址时
 	err = p.SetAddressByInstances([]*discovery.MicroServiceInstance{
@@ -187,7 +212,7 @@ func TestAddressPool_SetAddressByInstancesForDiffAzAddress(t *testing.T) {
 		},
 	})
 	assert.NoError(t, err)
-	assert.Equal(t, []string{"192.168.3.5:30100"}, p.diffAzAddress)
+	assert.Equal(t, []string{"192.168.3.5:30100"}, p.diffAzAddress[ProtocolRest])
 
 	// SetAddressByInstances仅传入对端地址时
 	err = p.SetAddressByInstances([]*discovery.MicroServiceInstance{
@@ -196,7 +221,7 @@ func TestAddressPool_SetAddressByInstancesForDiffAzAddress(t *testing.T) {
 		},
 	})
 	assert.NoError(t, err)
-	assert.Equal(t, []string{"192.168.3.5:30100"}, p.diffAzAddress)
+	assert.Equal(t, []string{"192.168.3.5:30100"}, p.diffAzAddress[ProtocolRest])
 
 	// SetAddressByInstances同时传入本端和对端地址时
 	err = p.SetAddressByInstances([]*discovery.MicroServiceInstance{
@@ -218,7 +243,7 @@ func TestAddressPool_SetAddressByInstancesForDiffAzAddress(t *testing.T) {
 		},
 	})
 	assert.NoError(t, err)
-	assert.Equal(t, []string{"192.168.3.5:30100"}, p.diffAzAddress)
+	assert.Equal(t, []string{"192.168.3.5:30100"}, p.diffAzAddress[ProtocolRest])
 
 	// SetAddressByInstances 两个地址都传入为同AZ地址时
 	err = p.SetAddressByInstances([]*discovery.MicroServiceInstance{
@@ -240,7 +265,7 @@ func TestAddressPool_SetAddressByInstancesForDiffAzAddress(t *testing.T) {
 		},
 	})
 	assert.NoError(t, err)
-	assert.Equal(t, []string{"192.168.3.5:30100"}, p.diffAzAddress)
+	assert.Equal(t, []string{"192.168.3.5:30100"}, p.diffAzAddress[ProtocolRest])
 }
 
 func TestAddressPool_checkConnectivity(t *testing.T) {
@@ -272,7 +297,6 @@ func TestAddressPool_checkConnectivity(t *testing.T) {
 
 func TestPool_CheckReadiness(t *testing.T) {
 	type fields struct {
-		mutex         sync.RWMutex
 		statusHistory []map[string]string
 	}
 	tests := []struct {
@@ -404,7 +428,6 @@ func TestPool_CheckReadiness(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			p := &Pool{
-				mutex:         tt.fields.mutex,
 				statusHistory: tt.fields.statusHistory,
 			}
 			assert.Equalf(t, tt.want, p.CheckReadiness(), "CheckReadiness()")
@@ -422,7 +445,7 @@ func TestPool_doCheckConnectivity(t *testing.T) {
 
 	// http probe is empty，use tcp probe
 	p := NewPool([]string{server1Addr})
-	assert.NoError(t, p.doCheckConnectivity(server1Addr))
+	assert.NoError(t, p.doCheckConnectivity(context.Background(), server1Addr))
 	assert.False(t, server1HttpCalled)
 
 	// http probe is not empty
@@ -430,10 +453,10 @@ func TestPool_doCheckConnectivity(t *testing.T) {
 		Protocol: "http",
 		Path:     "/",
 	}})
-	assert.NoError(t, p.doCheckConnectivity(server1Addr))
+	assert.NoError(t, p.doCheckConnectivity(context.Background(), server1Addr))
 	assert.True(t, server1HttpCalled)
 	server1.Close()
-	assert.Error(t, p.doCheckConnectivity(server1Addr))
+	assert.Error(t, p.doCheckConnectivity(context.Background(), server1Addr))
 
 	// http probe got 404，tcp probe again
 	server1HttpCalled = false
@@ -448,10 +471,10 @@ func TestPool_doCheckConnectivity(t *testing.T) {
 		Protocol: "http",
 		Path:     "/", // wrong path, got 404
 	}})
-	assert.NoError(t, p.doCheckConnectivity(server1Addr))
+	assert.NoError(t, p.doCheckConnectivity(context.Background(), server1Addr))
 	assert.False(t, server1HttpCalled)
 	p.httpProbeOptions.Path = "/test" // right path
-	assert.NoError(t, p.doCheckConnectivity(server1Addr))
+	assert.NoError(t, p.doCheckConnectivity(context.Background(), server1Addr))
 	assert.True(t, server1HttpCalled)
 	server1.Close()
 
@@ -463,10 +486,184 @@ func TestPool_doCheckConnectivity(t *testing.T) {
 	}))
 	server1Addr = server1.Listener.Addr().String()
 	p = NewPool([]string{server1Addr}, Options{HttpProbeOptions: &HttpProbeOptions{
-		Protocol: "https",
-		Path:     "/", // wrong path, got 404
+		Protocol:           "https",
+		Path:               "/", // wrong path, got 404
+		InsecureSkipVerify: true,
 	}})
-	assert.NoError(t, p.doCheckConnectivity(server1Addr))
+	assert.NoError(t, p.doCheckConnectivity(context.Background(), server1Addr))
 	assert.True(t, server1HttpCalled)
 	server1.Close()
 }
+
+func TestPool_doCheckConnectivityWithHttp_options(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(writer http.ResponseWriter, request *http.Request) {
+		if request.Header.Get("Authorization") != "Bearer token" {
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if request.Method == http.MethodHead {
+			return
+		}
+		_, _ = writer.Write([]byte(`{"status":"UP"}`))
+	})
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+	certPool := x509.NewCertPool()
+	certPool.AddCert(server.Certificate())
+
+	newPool := func(opts *HttpProbeOptions) *Pool {
+		p := NewPool([]string{}, Options{HttpProbeOptions: opts})
+		p.Close()
+		return p
+	}
+
+	t.Run("verify server certificate", func(t *testing.T) {
+		p := newPool(&HttpProbeOptions{
+			Protocol:  "https",
+			Path:      "/health",
+			TLSConfig: &tls.Config{RootCAs: certPool},
+			Headers:   http.Header{"Authorization": []string{"Bearer token"}},
+		})
+		assert.NoError(t, p.doCheckConnectivity(context.Background(), addr))
+
+		p = newPool(&HttpProbeOptions{
+			Protocol:  "https",
+			Path:      "/health",
+			TLSConfig: &tls.Config{},
+			Headers:   http.Header{"Authorization": []string{"Bearer token"}},
+		})
+		assert.Error(t, p.doCheckConnectivity(context.Background(), addr))
+	})
+	t.Run("sign request and expected status", func(t *testing.T) {
+		p := newPool(&HttpProbeOptions{
+			Protocol:  "https",
+			Path:      "/health",
+			Method:    http.MethodHead,
+			TLSConfig: &tls.Config{RootCAs: certPool},
+			SignRequest: func(request *http.Request) error {
+				request.Header.Set("Authorization", "Bearer token")
+				return nil
+			},
+		})
+		assert.NoError(t, p.doCheckConnectivity(context.Background(), addr))
+
+		p = newPool(&HttpProbeOptions{Protocol: "https", Path: "/health", InsecureSkipVerify: true})
+		assert.Error(t, p.doCheckConnectivity(context.Background(), addr))
+		p = newPool(&HttpProbeOptions{Protocol: "https", Path: "/health", InsecureSkipVerify: true,
+			ExpectedStatus: []int{http.StatusUnauthorized}})
+		assert.NoError(t, p.doCheckConnectivity(context.Background(), addr))
+	})
+	t.Run("body matcher", func(t *testing.T) {
+		opts := &HttpProbeOptions{
+			Protocol:  "https",
+			Path:      "/health",
+			TLSConfig: &tls.Config{RootCAs: certPool},
+			Headers:   http.Header{"Authorization": []string{"Bearer token"}},
+			BodyMatcher: func(body []byte) bool {
+				return strings.Contains(string(body), `"UP"`)
+			},
+		}
+		assert.NoError(t, newPool(opts).doCheckConnectivity(context.Background(), addr))
+		opts.BodyMatcher = func(body []byte) bool {
+			return false
+		}
+		assert.Error(t, newPool(opts).doCheckConnectivity(context.Background(), addr))
+	})
+	t.Run("disable tcp fallback, 404 should be unavailable", func(t *testing.T) {
+		p := newPool(&HttpProbeOptions{Protocol: "https", Path: "/not-exist", InsecureSkipVerify: true})
+		assert.NoError(t, p.doCheckConnectivity(context.Background(), addr))
+		p = newPool(&HttpProbeOptions{Protocol: "https", Path: "/not-exist", InsecureSkipVerify: true,
+			DisableTCPFallback: true})
+		assert.Error(t, p.doCheckConnectivity(context.Background(), addr))
+	})
+	t.Run("skip verify only if opted in", func(t *testing.T) {
+		p := newPool(&HttpProbeOptions{Protocol: "https", Path: "/health", Headers: http.Header{"Authorization": []string{"Bearer token"}}})
+		assert.Error(t, p.doCheckConnectivity(context.Background(), addr))
+		p = newPool(&HttpProbeOptions{Protocol: "https", Path: "/health", Headers: http.Header{"Authorization": []string{"Bearer token"}},
+			InsecureSkipVerify: true})
+		assert.NoError(t, p.doCheckConnectivity(context.Background(), addr))
+	})
+}
+
+func TestPool_probeAll_https(t *testing.T) {
+	certPool := x509.NewCertPool()
+	var targets []probeTarget
+	for i := 0; i < 4; i++ {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if request.Header.Get("Authorization") != "Bearer token" || request.Header.Get("X-Probe") != "1" {
+				writer.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+		defer server.Close()
+		certPool.AddCert(server.Certificate())
+		targets = append(targets, probeTarget{protocol: ProtocolRest, addr: server.Listener.Addr().String()})
+	}
+
+	p := NewPool([]string{}, Options{
+		HttpProbeOptions: &HttpProbeOptions{
+			Protocol:  "https",
+			Path:      "/health",
+			TLSConfig: &tls.Config{RootCAs: certPool},
+			Headers:   http.Header{"X-Probe": []string{"1"}},
+			SignRequest: func(request *http.Request) error {
+				request.Header.Set("Authorization", "Bearer token")
+				return nil
+			},
+		},
+		ProbeOptions: &ProbeOptions{Concurrency: 4},
+	})
+	defer p.Close()
+	// later rounds reuse the keep-alive connections of the first one
+	for round := 0; round < 3; round++ {
+		for _, err := range p.probeAll(context.Background(), targets) {
+			assert.NoError(t, err)
+		}
+	}
+}
+
+func TestAddressPool_GetAvailableAddressFor(t *testing.T) {
+	p := NewPool([]string{"192.168.2.1:30100"})
+	defer p.Close()
+
+	err := p.SetAddressByInstances([]*discovery.MicroServiceInstance{
+		{
+			Endpoints:      []string{"rest://192.168.1.1:30100", "grpc://192.168.1.1:30101", "highway://192.168.1.1:30102"},
+			DataCenterInfo: &discovery.DataCenterInfo{Region: "cn", AvailableZone: "az1"},
+		},
+		{
+			Endpoints:      []string{"grpc://192.168.3.1:30101"},
+			DataCenterInfo: &discovery.DataCenterInfo{Region: "cn", AvailableZone: "az3"},
+		},
+		{
+			Endpoints:      []string{"rest://192.168.2.1:30100", "grpc://192.168.2.1:30101"},
+			DataCenterInfo: &discovery.DataCenterInfo{Region: "cn", AvailableZone: "az2"},
+		},
+		{
+			Endpoints: []string{"rest://192.168.2.2:30100", "grpc://192.168.2.2:30101"}, // no az, not in default
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"192.168.2.1:30100"}, p.sameAzAddress[ProtocolRest])
+	assert.Equal(t, []string{"192.168.2.1:30101"}, p.sameAzAddress[ProtocolGrpc])
+	assert.Equal(t, []string{"192.168.1.1:30100", "192.168.2.2:30100"}, p.diffAzAddress[ProtocolRest])
+	assert.Equal(t, []string{"192.168.1.1:30101", "192.168.3.1:30101", "192.168.2.2:30101"}, p.diffAzAddress[ProtocolGrpc])
+	assert.Equal(t, []string{"192.168.1.1:30102"}, p.diffAzAddress["highway"])
+
+	p.mutex.Lock()
+	p.status["192.168.2.1:30101"] = statusUnavailable
+	p.status["192.168.3.1:30101"] = statusAvailable
+	p.status["192.168.1.1:30102"] = statusAvailable
+	p.mutex.Unlock()
+	assert.Equal(t, "192.168.3.1:30101", p.GetAvailableAddressFor(ProtocolGrpc))
+	assert.Equal(t, "192.168.1.1:30102", p.GetAvailableAddressFor("highway"))
+	assert.Equal(t, "", p.GetAvailableAddressFor("unknown"))
+	assert.Equal(t, "192.168.2.1:30100", p.GetAvailableAddress(), "rest should fall back to default")
+
+	targets := p.probeTargets()
+	assert.Equal(t, probeTarget{protocol: ProtocolRest, addr: "192.168.2.1:30100"}, targets[0])
+	assert.Contains(t, targets, probeTarget{protocol: ProtocolGrpc, addr: "192.168.3.1:30101"})
+	assert.Contains(t, targets, probeTarget{protocol: "highway", addr: "192.168.1.1:30102"})
+	assert.Len(t, targets, 8)
+}
@@ -0,0 +1,144 @@
+package addresspool
+
+import (
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	httpProbeTimeout = 5 * time.Second
+	maxProbeBodySize = 1 << 20
+
+	defaultProbeConcurrency = 8
+
+	defaultHistoryDepth              = 3
+	defaultReadinessSuccessThreshold = 2
+	defaultReadinessFailureThreshold = 3
+)
+
+// ProbeOptions configures the schedule of the active health probe, zero values use the defaults
+type ProbeOptions struct {
+	// Interval between two probe rounds, default is the seconds in env CHASSIS_SC_HEALTH_CHECK_INTERVAL, or 15s
+	Interval time.Duration
+	// Timeout of probing one endpoint, default 1s for tcp and 5s for http
+	Timeout time.Duration
+	// Jitter a random duration in [0, Jitter) is added to each interval, to spread the probes of many clients
+	Jitter time.Duration
+	// HistoryDepth the count of probe rounds kept for CheckReadiness, default 3,
+	// it is raised to the readiness thresholds if less than them
+	HistoryDepth int
+	// ReadinessSuccessThreshold the rounds in a row with available endpoints to be ReadinessSuccess, default 2
+	ReadinessSuccessThreshold int
+	// ReadinessFailureThreshold the rounds in a row without available endpoints to be ReadinessFailed, default 3
+	ReadinessFailureThreshold int
+	// Concurrency the max count of endpoints probed at the same time, default 8
+	Concurrency int
+	// RoundTimeout the deadline of a whole probe round, default is the Interval,
+	// the endpoints not probed before the deadline are unavailable
+	RoundTimeout time.Duration
+}
+
+// SetProbeOptions changes the probe schedule of a live pool, it takes effect from the next probe round
+func (p *Pool) SetProbeOptions(opts ProbeOptions) {
+	p.mutex.Lock()
+	p.probeOptions = opts
+	p.trimStatusHistory()
+	p.mutex.Unlock()
+
+	select {
+	case p.probeOptionsChanged <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Pool) nextProbeDelay() time.Duration {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	interval := p.probeInterval()
+	if p.probeOptions.Jitter > 0 {
+		interval += time.Duration(rand.Int63n(int64(p.probeOptions.Jitter)))
+	}
+	return interval
+}
+
+func (p *Pool) probeInterval() time.Duration {
+	if p.probeOptions.Interval > 0 {
+		return p.probeOptions.Interval
+	}
+	return defaultProbeInterval()
+}
+
+func (p *Pool) probeRoundTimeout() time.Duration {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.probeOptions.RoundTimeout > 0 {
+		return p.probeOptions.RoundTimeout
+	}
+	return p.probeInterval()
+}
+
+func (p *Pool) probeConcurrency() int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.probeOptions.Concurrency > 0 {
+		return p.probeOptions.Concurrency
+	}
+	return defaultProbeConcurrency
+}
+
+func defaultProbeInterval() time.Duration {
+	v, isExist := os.LookupEnv(EnvCheckScInterval)
+	if !isExist {
+		return defaultCheckScIntervalInSecond * time.Second
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || i <= 0 {
+		return defaultCheckScIntervalInSecond * time.Second
+	}
+	return time.Duration(i) * time.Second
+}
+
+func (p *Pool) probeTimeout(defaultTimeout time.Duration) time.Duration {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.probeOptions.Timeout > 0 {
+		return p.probeOptions.Timeout
+	}
+	return defaultTimeout
+}
+
+func (p *Pool) readinessThresholds() (success int, failure int) {
+	success = p.probeOptions.ReadinessSuccessThreshold
+	if success <= 0 {
+		success = defaultReadinessSuccessThreshold
+	}
+	failure = p.probeOptions.ReadinessFailureThreshold
+	if failure <= 0 {
+		failure = defaultReadinessFailureThreshold
+	}
+	return success, failure
+}
+
+func (p *Pool) historyDepth() int {
+	depth := p.probeOptions.HistoryDepth
+	if depth <= 0 {
+		depth = defaultHistoryDepth
+	}
+	success, failure := p.readinessThresholds()
+	if depth < success {
+		depth = success
+	}
+	if depth < failure {
+		depth = failure
+	}
+	return depth
+}
+
+func (p *Pool) trimStatusHistory() {
+	depth := p.historyDepth()
+	if cnt := len(p.statusHistory); cnt > depth {
+		p.statusHistory = p.statusHistory[(cnt - depth):]
+	}
+}
@@ -0,0 +1,133 @@
+package addresspool
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool_SetProbeOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {}))
+	addr := server.Listener.Addr().String()
+	p := NewPool([]string{addr}, Options{ProbeOptions: &ProbeOptions{Interval: time.Hour}})
+	defer p.Close()
+	assert.Equal(t, time.Hour, p.nextProbeDelay())
+
+	server.Close()
+	p.SetProbeOptions(ProbeOptions{Interval: 100 * time.Millisecond, Jitter: 10 * time.Millisecond})
+	delay := p.nextProbeDelay()
+	assert.True(t, delay >= 100*time.Millisecond && delay < 110*time.Millisecond)
+
+	time.Sleep(500 * time.Millisecond)
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	assert.Equal(t, statusUnavailable, p.status[addr], "the new interval should take effect")
+}
+
+func TestPool_CheckReadiness_thresholds(t *testing.T) {
+	available := map[string]string{"1.1.1.1:30110": statusAvailable}
+	unavailable := map[string]string{"1.1.1.1:30110": statusUnavailable}
+
+	p := &Pool{
+		probeOptions: ProbeOptions{
+			HistoryDepth:              1,
+			ReadinessSuccessThreshold: 1,
+			ReadinessFailureThreshold: 4,
+		},
+	}
+	assert.Equal(t, 4, p.historyDepth(), "should be raised to the thresholds")
+
+	p.statusHistory = []map[string]string{unavailable, available}
+	assert.Equal(t, ReadinessSuccess, p.CheckReadiness())
+
+	p.statusHistory = []map[string]string{available, unavailable, unavailable, unavailable}
+	assert.Equal(t, ReadinessIndeterminate, p.CheckReadiness())
+
+	p.statusHistory = append(p.statusHistory, unavailable)
+	p.trimStatusHistory()
+	assert.Len(t, p.statusHistory, 4)
+	assert.Equal(t, ReadinessFailed, p.CheckReadiness())
+}
+
+func Test_defaultProbeInterval(t *testing.T) {
+	t.Setenv(EnvCheckScInterval, "")
+	assert.Equal(t, 15*time.Second, defaultProbeInterval())
+	t.Setenv(EnvCheckScInterval, "3")
+	assert.Equal(t, 3*time.Second, defaultProbeInterval())
+}
+
+func newSlowServers(n int, delay time.Duration) ([]*httptest.Server, []string) {
+	servers := make([]*httptest.Server, 0, n)
+	addrs := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			select {
+			case <-time.After(delay):
+			case <-request.Context().Done():
+			}
+		}))
+		servers = append(servers, server)
+		addrs = append(addrs, server.Listener.Addr().String())
+	}
+	return servers, addrs
+}
+
+func TestPool_checkConnectivity_concurrency(t *testing.T) {
+	servers, addrs := newSlowServers(4, 300*time.Millisecond)
+	defer func() {
+		for _, s := range servers {
+			s.Close()
+		}
+	}()
+	p := NewPool(addrs, Options{
+		HttpProbeOptions: &HttpProbeOptions{Protocol: "http"},
+		ProbeOptions:     &ProbeOptions{Interval: time.Hour, Concurrency: 4},
+	})
+	defer p.Close()
+
+	t.Run("endpoints should be probed in parallel", func(t *testing.T) {
+		start := time.Now()
+		p.checkConnectivity()
+		assert.Less(t, int64(time.Since(start)), int64(time.Second))
+		for _, addr := range addrs {
+			assert.Equal(t, statusAvailable, p.status[addr])
+		}
+	})
+	t.Run("round timeout, endpoints not probed should be unavailable", func(t *testing.T) {
+		p.SetProbeOptions(ProbeOptions{Interval: time.Hour, Concurrency: 1, RoundTimeout: 100 * time.Millisecond})
+		start := time.Now()
+		p.checkConnectivity()
+		assert.Less(t, int64(time.Since(start)), int64(time.Second))
+		for _, addr := range addrs {
+			assert.Equal(t, statusUnavailable, p.status[addr])
+		}
+	})
+}
+
+func TestPool_Close_cancelProbes(t *testing.T) {
+	servers, addrs := newSlowServers(1, 10*time.Second)
+	defer servers[0].Close()
+	p := NewPool([]string{}, Options{
+		HttpProbeOptions: &HttpProbeOptions{Protocol: "http"},
+		ProbeOptions:     &ProbeOptions{Interval: time.Hour},
+	})
+	p.ResetAddress(addrs)
+	p.status[addrs[0]] = statusAvailable
+
+	done := make(chan struct{})
+	go func() {
+		p.checkConnectivity()
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	p.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("probe is not cancelled")
+	}
+	assert.Equal(t, statusAvailable, p.status[addrs[0]], "status should not be changed by cancelled probes")
+}
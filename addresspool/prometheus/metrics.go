@@ -0,0 +1,190 @@
+// Package prometheus exports the addresspool metrics to prometheus
+package prometheus
+
+import (
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/go-chassis/cari/addresspool"
+)
+
+const (
+	labelPool     = "pool"
+	labelProtocol = "protocol"
+	labelAddress  = "address"
+	labelResult   = "result"
+	labelTier     = "tier"
+
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+var tiers = []addresspool.Tier{
+	addresspool.TierNone,
+	addresspool.TierSameAz,
+	addresspool.TierSameRegion,
+	addresspool.TierDiffAz,
+	addresspool.TierDefault,
+}
+
+// Options of the collector
+type Options struct {
+	Namespace string // default "cari"
+	Subsystem string // default "addresspool"
+	// Pool is the value of the "pool" label, to tell the metrics of multiple pools
+	Pool string
+	// Buckets of the probe latency histogram in seconds, default prometheus.DefBuckets
+	Buckets []float64
+}
+
+// Collector implements addresspool.Metrics and prometheus.Collector,
+// set it as addresspool.Options.Metrics and register it to a prometheus registry
+type Collector struct {
+	probeLatency *prom.HistogramVec
+	probeTotal   *prom.CounterVec
+	status       *prom.GaugeVec
+	tier         *prom.GaugeVec
+	pickTotal    *prom.CounterVec
+
+	mutex     sync.Mutex
+	protocols map[string]map[string]struct{} // protocols of the addresses, to delete the series of them
+}
+
+// NewCollector creates a collector
+func NewCollector(opts Options) *Collector {
+	if len(opts.Namespace) == 0 {
+		opts.Namespace = "cari"
+	}
+	if len(opts.Subsystem) == 0 {
+		opts.Subsystem = "addresspool"
+	}
+	if len(opts.Buckets) == 0 {
+		opts.Buckets = prom.DefBuckets
+	}
+	constLabels := prom.Labels{labelPool: opts.Pool}
+	return &Collector{
+		probeLatency: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        "probe_duration_seconds",
+			Help:        "Latency of the active health probes",
+			ConstLabels: constLabels,
+			Buckets:     opts.Buckets,
+		}, []string{labelProtocol, labelAddress}),
+		probeTotal: prom.NewCounterVec(prom.CounterOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        "probes_total",
+			Help:        "Count of the active health probes by result",
+			ConstLabels: constLabels,
+		}, []string{labelProtocol, labelAddress, labelResult}),
+		status: prom.NewGaugeVec(prom.GaugeOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        "endpoint_available",
+			Help:        "1 if the endpoint is available, otherwise 0",
+			ConstLabels: constLabels,
+		}, []string{labelAddress}),
+		tier: prom.NewGaugeVec(prom.GaugeOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        "tier_in_use",
+			Help:        "1 for the tier which the addresses of the protocol are picked from, otherwise 0",
+			ConstLabels: constLabels,
+		}, []string{labelProtocol, labelTier}),
+		pickTotal: prom.NewCounterVec(prom.CounterOpts{
+			Namespace:   opts.Namespace,
+			Subsystem:   opts.Subsystem,
+			Name:        "picks_total",
+			Help:        "Count of the picked addresses",
+			ConstLabels: constLabels,
+		}, []string{labelProtocol, labelTier, labelAddress}),
+		protocols: make(map[string]map[string]struct{}),
+	}
+}
+
+func (c *Collector) addProtocol(addr, protocol string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	protocols, ok := c.protocols[addr]
+	if !ok {
+		protocols = make(map[string]struct{})
+		c.protocols[addr] = protocols
+	}
+	protocols[protocol] = struct{}{}
+}
+
+// ObserveProbe implements addresspool.Metrics
+func (c *Collector) ObserveProbe(protocol, addr string, latency time.Duration, err error) {
+	c.addProtocol(addr, protocol)
+	c.probeLatency.WithLabelValues(protocol, addr).Observe(latency.Seconds())
+	result := resultSuccess
+	if err != nil {
+		result = resultFailure
+	}
+	c.probeTotal.WithLabelValues(protocol, addr, result).Inc()
+}
+
+// SetEndpointStatus implements addresspool.Metrics
+func (c *Collector) SetEndpointStatus(addr string, available bool) {
+	v := 0.0
+	if available {
+		v = 1
+	}
+	c.status.WithLabelValues(addr).Set(v)
+}
+
+// DeleteEndpoint implements addresspool.Metrics, the series of the endpoint are deleted
+func (c *Collector) DeleteEndpoint(addr string) {
+	c.status.DeleteLabelValues(addr)
+
+	c.mutex.Lock()
+	protocols := c.protocols[addr]
+	delete(c.protocols, addr)
+	c.mutex.Unlock()
+	for protocol := range protocols {
+		c.probeLatency.DeleteLabelValues(protocol, addr)
+		c.probeTotal.DeleteLabelValues(protocol, addr, resultSuccess)
+		c.probeTotal.DeleteLabelValues(protocol, addr, resultFailure)
+		for _, tier := range tiers {
+			c.pickTotal.DeleteLabelValues(protocol, string(tier), addr)
+		}
+	}
+}
+
+// SetTier implements addresspool.Metrics
+func (c *Collector) SetTier(protocol string, tier addresspool.Tier) {
+	for _, t := range tiers {
+		v := 0.0
+		if t == tier {
+			v = 1
+		}
+		c.tier.WithLabelValues(protocol, string(t)).Set(v)
+	}
+}
+
+// IncPick implements addresspool.Metrics
+func (c *Collector) IncPick(protocol string, tier addresspool.Tier, addr string) {
+	c.addProtocol(addr, protocol)
+	c.pickTotal.WithLabelValues(protocol, string(tier), addr).Inc()
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prom.Desc) {
+	c.probeLatency.Describe(ch)
+	c.probeTotal.Describe(ch)
+	c.status.Describe(ch)
+	c.tier.Describe(ch)
+	c.pickTotal.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	c.probeLatency.Collect(ch)
+	c.probeTotal.Collect(ch)
+	c.status.Collect(ch)
+	c.tier.Collect(ch)
+	c.pickTotal.Collect(ch)
+}
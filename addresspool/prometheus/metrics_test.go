@@ -0,0 +1,74 @@
+package prometheus_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/cari/addresspool"
+	"github.com/go-chassis/cari/addresspool/prometheus"
+)
+
+func TestCollector(t *testing.T) {
+	c := prometheus.NewCollector(prometheus.Options{Pool: "sc"})
+	registry := prom.NewPedanticRegistry()
+	assert.NoError(t, registry.Register(c))
+
+	c.ObserveProbe(addresspool.ProtocolRest, "127.0.0.1:30100", 10*time.Millisecond, nil)
+	c.ObserveProbe(addresspool.ProtocolRest, "127.0.0.1:30100", 10*time.Millisecond, errors.New("refused"))
+	c.SetEndpointStatus("127.0.0.1:30100", true)
+	c.SetTier(addresspool.ProtocolRest, addresspool.TierSameAz)
+	c.IncPick(addresspool.ProtocolRest, addresspool.TierSameAz, "127.0.0.1:30100")
+
+	err := testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP cari_addresspool_endpoint_available 1 if the endpoint is available, otherwise 0
+# TYPE cari_addresspool_endpoint_available gauge
+cari_addresspool_endpoint_available{address="127.0.0.1:30100",pool="sc"} 1
+# HELP cari_addresspool_picks_total Count of the picked addresses
+# TYPE cari_addresspool_picks_total counter
+cari_addresspool_picks_total{address="127.0.0.1:30100",pool="sc",protocol="rest",tier="sameAz"} 1
+# HELP cari_addresspool_probes_total Count of the active health probes by result
+# TYPE cari_addresspool_probes_total counter
+cari_addresspool_probes_total{address="127.0.0.1:30100",pool="sc",protocol="rest",result="failure"} 1
+cari_addresspool_probes_total{address="127.0.0.1:30100",pool="sc",protocol="rest",result="success"} 1
+`), "cari_addresspool_endpoint_available", "cari_addresspool_picks_total", "cari_addresspool_probes_total")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, testutil.CollectAndCount(c, "cari_addresspool_tier_in_use"))
+	assert.Equal(t, 1, testutil.CollectAndCount(c, "cari_addresspool_probe_duration_seconds"))
+
+	c.DeleteEndpoint("127.0.0.1:30100")
+	assert.Equal(t, 0, testutil.CollectAndCount(c, "cari_addresspool_endpoint_available"))
+	assert.Equal(t, 0, testutil.CollectAndCount(c, "cari_addresspool_probes_total"))
+	assert.Equal(t, 0, testutil.CollectAndCount(c, "cari_addresspool_picks_total"))
+	assert.Equal(t, 0, testutil.CollectAndCount(c, "cari_addresspool_probe_duration_seconds"))
+}
+
+func TestCollector_pool(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer svr.Close()
+	addr := strings.TrimPrefix(svr.URL, "http://")
+
+	c := prometheus.NewCollector(prometheus.Options{})
+	p := addresspool.NewPool([]string{addr}, addresspool.Options{Metrics: c})
+	defer p.Close()
+
+	assert.Equal(t, addr, p.GetAvailableAddress())
+	err := testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP cari_addresspool_endpoint_available 1 if the endpoint is available, otherwise 0
+# TYPE cari_addresspool_endpoint_available gauge
+cari_addresspool_endpoint_available{address="`+addr+`",pool=""} 1
+# HELP cari_addresspool_picks_total Count of the picked addresses
+# TYPE cari_addresspool_picks_total counter
+cari_addresspool_picks_total{address="`+addr+`",pool="",protocol="rest",tier="default"} 1
+`), "cari_addresspool_endpoint_available", "cari_addresspool_picks_total")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, testutil.CollectAndCount(c, "cari_addresspool_tier_in_use"))
+	assert.Equal(t, 1, testutil.CollectAndCount(c, "cari_addresspool_probes_total"))
+}
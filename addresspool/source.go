@@ -0,0 +1,122 @@
+package addresspool
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-chassis/openlog"
+
+	"github.com/go-chassis/cari/discovery"
+)
+
+const defaultRefreshInterval = 30 * time.Second
+
+// EndpointSource provides the instances of the servers, e.g. a registry client discovering
+// the instances of its own service center cluster
+type EndpointSource interface {
+	FetchInstances(ctx context.Context) ([]*discovery.MicroServiceInstance, error)
+}
+
+// EndpointWatcher is an EndpointSource which can also push the instances when they are changed,
+// the pool still polls it by the RefreshInterval in case of missing pushes
+type EndpointWatcher interface {
+	EndpointSource
+	// Watch calls onChange with the latest instances until ctx is done, it blocks
+	Watch(ctx context.Context, onChange func([]*discovery.MicroServiceInstance)) error
+}
+
+// EndpointSourceFunc is an adapter to use a function as an EndpointSource
+type EndpointSourceFunc func(ctx context.Context) ([]*discovery.MicroServiceInstance, error)
+
+// FetchInstances calls f(ctx)
+func (f EndpointSourceFunc) FetchInstances(ctx context.Context) ([]*discovery.MicroServiceInstance, error) {
+	return f(ctx)
+}
+
+// RefreshEndpoints fetches the instances from Options.EndpointSource and sets the addresses by them,
+// the addresses are kept if it fails. It returns nil if no source is set
+func (p *Pool) RefreshEndpoints(ctx context.Context) error {
+	if p.endpointSource == nil {
+		return nil
+	}
+	instances, err := p.endpointSource.FetchInstances(ctx)
+	if err != nil {
+		return fmt.Errorf("fetch instances failed: %w", err)
+	}
+	return p.setInstances(instances)
+}
+
+// setInstances sets the addresses by the instances, a probe round is triggered if there are new addresses,
+// so they are available without waiting for the next scheduled round
+func (p *Pool) setInstances(instances []*discovery.MicroServiceInstance) error {
+	if err := p.SetAddressByInstances(instances); err != nil {
+		return err
+	}
+	if !p.hasUnprobedAddress() {
+		return nil
+	}
+	select {
+	case p.endpointsChanged <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (p *Pool) hasUnprobedAddress() bool {
+	targets := p.probeTargets()
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	for _, target := range targets {
+		if _, ok := p.status[target.addr]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Pool) refreshInterval() time.Duration {
+	if p.endpointRefreshInterval > 0 {
+		return p.endpointRefreshInterval
+	}
+	return defaultRefreshInterval
+}
+
+// startRefresh polls the endpoint source until the pool is closed, and watches it if it is an EndpointWatcher
+func (p *Pool) startRefresh() {
+	if p.endpointSource == nil {
+		return
+	}
+	if w, ok := p.endpointSource.(EndpointWatcher); ok {
+		go func() {
+			err := w.Watch(p.ctx, func(instances []*discovery.MicroServiceInstance) {
+				if err := p.setInstances(instances); err != nil {
+					openlog.Warn(fmt.Sprintf("ignore the pushed endpoints: %s", err))
+				}
+			})
+			if err != nil && p.ctx.Err() == nil {
+				openlog.Error(fmt.Sprintf("watch endpoints failed, fall back to polling: %s", err))
+			}
+		}()
+	}
+	go func() {
+		ticker := time.NewTicker(p.refreshInterval())
+		defer ticker.Stop()
+		for {
+			p.refresh()
+			select {
+			case <-ticker.C:
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (p *Pool) refresh() {
+	ctx, cancel := context.WithTimeout(p.ctx, p.refreshInterval())
+	defer cancel()
+	if err := p.RefreshEndpoints(ctx); err != nil && p.ctx.Err() == nil {
+		openlog.Error(fmt.Sprintf("refresh endpoints failed, keep the last ones: %s", err))
+	}
+}
@@ -0,0 +1,156 @@
+package addresspool
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/cari/discovery"
+)
+
+type fakeSource struct {
+	mutex     sync.Mutex
+	instances []*discovery.MicroServiceInstance
+	err       error
+	fetched   int
+}
+
+func (s *fakeSource) FetchInstances(_ context.Context) ([]*discovery.MicroServiceInstance, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fetched++
+	return s.instances, s.err
+}
+
+func (s *fakeSource) set(instances []*discovery.MicroServiceInstance, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.instances = instances
+	s.err = err
+}
+
+type fakeWatcher struct {
+	fakeSource
+	push chan []*discovery.MicroServiceInstance
+}
+
+func (w *fakeWatcher) Watch(ctx context.Context, onChange func([]*discovery.MicroServiceInstance)) error {
+	for {
+		select {
+		case instances := <-w.push:
+			onChange(instances)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func restInstance(addr string) *discovery.MicroServiceInstance {
+	return &discovery.MicroServiceInstance{Endpoints: []string{"rest://" + addr}}
+}
+
+func TestPool_RefreshEndpoints(t *testing.T) {
+	p := NewPool([]string{"127.0.0.1:30100"})
+	defer p.Close()
+	assert.NoError(t, p.RefreshEndpoints(context.Background()), "no source should do nothing")
+
+	source := &fakeSource{instances: []*discovery.MicroServiceInstance{restInstance("127.0.0.2:30100")}}
+	p = NewPool([]string{"127.0.0.1:30100"}, Options{EndpointSource: source, RefreshInterval: time.Hour})
+	defer p.Close()
+	assert.NoError(t, p.RefreshEndpoints(context.Background()))
+	p.mutex.RLock()
+	assert.Equal(t, []string{"127.0.0.2:30100"}, p.diffAzAddress[ProtocolRest])
+	p.mutex.RUnlock()
+
+	t.Run("fetch error, should keep the last good addresses", func(t *testing.T) {
+		source.set(nil, errors.New("unavailable"))
+		assert.Error(t, p.RefreshEndpoints(context.Background()))
+		p.mutex.RLock()
+		assert.Equal(t, []string{"127.0.0.2:30100"}, p.diffAzAddress[ProtocolRest])
+		p.mutex.RUnlock()
+	})
+	t.Run("no instances, should keep the last good addresses", func(t *testing.T) {
+		source.set(nil, nil)
+		assert.Error(t, p.RefreshEndpoints(context.Background()))
+		p.mutex.RLock()
+		assert.Equal(t, []string{"127.0.0.2:30100"}, p.diffAzAddress[ProtocolRest])
+		p.mutex.RUnlock()
+	})
+}
+
+func TestPool_RefreshEndpoints_deregistered(t *testing.T) {
+	instanceA := &discovery.MicroServiceInstance{Endpoints: []string{"rest://127.0.0.2:30100"},
+		DataCenterInfo: &discovery.DataCenterInfo{AvailableZone: "az1"}}
+	instanceB := &discovery.MicroServiceInstance{Endpoints: []string{"rest://127.0.0.3:30100"},
+		DataCenterInfo: &discovery.DataCenterInfo{AvailableZone: "az2"}}
+	source := &fakeSource{instances: []*discovery.MicroServiceInstance{instanceA}}
+	p := NewPool([]string{}, Options{
+		EndpointSource:  source,
+		RefreshInterval: time.Hour,
+		Locality:        &Locality{AvailableZone: "az1"},
+		ProbeOptions:    &ProbeOptions{Interval: time.Hour},
+	})
+	defer p.Close()
+	assert.NoError(t, p.RefreshEndpoints(context.Background()))
+
+	source.set([]*discovery.MicroServiceInstance{instanceB}, nil)
+	assert.NoError(t, p.RefreshEndpoints(context.Background()))
+	p.mutex.Lock()
+	assert.Empty(t, p.sameAzAddress[ProtocolRest], "instance A is deregistered")
+	assert.Equal(t, []string{"127.0.0.3:30100"}, p.diffAzAddress[ProtocolRest])
+	// A is still available by the last probe round
+	p.status = map[string]string{"127.0.0.2:30100": statusAvailable, "127.0.0.3:30100": statusAvailable}
+	p.mutex.Unlock()
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, "127.0.0.3:30100", p.GetAvailableAddress())
+	}
+}
+
+func TestPool_EndpointSource_poll(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer svr.Close()
+	addr := strings.TrimPrefix(svr.URL, "http://")
+
+	source := &fakeSource{}
+	p := NewPool([]string{"127.0.0.1:30100"}, Options{
+		EndpointSource:  source,
+		RefreshInterval: 10 * time.Millisecond,
+		ProbeOptions:    &ProbeOptions{Interval: time.Hour},
+	})
+	defer p.Close()
+
+	source.set([]*discovery.MicroServiceInstance{restInstance(addr)}, nil)
+	// the new address should be probed at once, without waiting for the next probe round
+	assert.Eventually(t, func() bool {
+		return p.GetAvailableAddress() == addr
+	}, 3*time.Second, 10*time.Millisecond)
+
+	p.Close()
+	source.mutex.Lock()
+	fetched := source.fetched
+	source.mutex.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	source.mutex.Lock()
+	assert.LessOrEqual(t, source.fetched, fetched+1, "should stop polling after closed")
+	source.mutex.Unlock()
+}
+
+func TestPool_EndpointSource_watch(t *testing.T) {
+	watcher := &fakeWatcher{push: make(chan []*discovery.MicroServiceInstance)}
+	p := NewPool([]string{"127.0.0.1:30100"}, Options{EndpointSource: watcher, RefreshInterval: time.Hour})
+	defer p.Close()
+
+	watcher.push <- []*discovery.MicroServiceInstance{restInstance("127.0.0.3:30100")}
+	assert.Eventually(t, func() bool {
+		p.mutex.RLock()
+		defer p.mutex.RUnlock()
+		return len(p.diffAzAddress[ProtocolRest]) == 1 && p.diffAzAddress[ProtocolRest][0] == "127.0.0.3:30100"
+	}, time.Second, 10*time.Millisecond)
+}
@@ -21,21 +21,44 @@ func getProtocolMap(eps []string) map[string]string {
 	return m
 }
 
-func getAzAddressMap(instances []*discovery.MicroServiceInstance) map[string][]string {
-	azAddrMap := make(map[string][]string) // key: az, value: address list
+// azGroup is the addresses of the instances in one az, keyed by protocol
+type azGroup struct {
+	region string
+	az     string
+	addrs  map[string][]string
+}
 
-	for _, instance := range instances {
-		azName := "unknown"
-		if instance.DataCenterInfo != nil && len(instance.DataCenterInfo.AvailableZone) > 0 {
-			azName = instance.DataCenterInfo.AvailableZone
-		}
+// getAzGroups groups the addresses of instances by region and az, in the order of the instances,
+// an instance without region and az is put in a group of its own
+func getAzGroups(instances []*discovery.MicroServiceInstance) []*azGroup {
+	groups := make([]*azGroup, 0)
+	azIndex := make(map[string]*azGroup)
 
+	for _, instance := range instances {
 		m := getProtocolMap(instance.Endpoints)
-		if ep := m["rest"]; len(ep) > 0 {
-			azAddrMap[azName] = append(azAddrMap[azName], ep)
+		if len(m) == 0 {
+			continue
+		}
+		var g *azGroup
+		if dc := instance.DataCenterInfo; dc != nil && (len(dc.Region) > 0 || len(dc.AvailableZone) > 0) {
+			key := dc.Region + "/" + dc.AvailableZone
+			g = azIndex[key]
+			if g == nil {
+				g = &azGroup{region: dc.Region, az: dc.AvailableZone, addrs: make(map[string][]string)}
+				azIndex[key] = g
+				groups = append(groups, g)
+			}
+		} else {
+			g = &azGroup{addrs: make(map[string][]string)}
+			groups = append(groups, g)
+		}
+		for protocol, ep := range m {
+			if len(ep) > 0 {
+				g.addrs[protocol] = append(g.addrs[protocol], ep)
+			}
 		}
 	}
-	return azAddrMap
+	return groups
 }
 
 func removeDuplicates(input []string) []string {
@@ -55,3 +78,11 @@ func removeDuplicates(input []string) []string {
 	}
 	return output
 }
+
+// dedupAddress removes the duplicated addresses of each protocol
+func dedupAddress(m map[string][]string) map[string][]string {
+	for protocol, addrList := range m {
+		m[protocol] = removeDuplicates(addrList)
+	}
+	return m
+}
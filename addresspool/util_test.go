@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/cari/discovery"
 )
 
 func Test_removeDuplicates(t *testing.T) {
@@ -11,3 +13,32 @@ func Test_removeDuplicates(t *testing.T) {
 	assert.Equal(t, []string{"v1", "v2"}, removeDuplicates([]string{"v1", "v2"}))
 	assert.Equal(t, []string{"v1", "v2", "v3"}, removeDuplicates([]string{"v1", "v2", "v2", "v1", "v1", "v3"}))
 }
+
+func Test_getAzGroups(t *testing.T) {
+	groups := getAzGroups([]*discovery.MicroServiceInstance{
+		{
+			Endpoints:      []string{"rest://127.0.0.1:30100", "grpc://127.0.0.1:30101"},
+			DataCenterInfo: &discovery.DataCenterInfo{AvailableZone: "az1"},
+		},
+		{Endpoints: []string{"rest://127.0.0.2:30100"}},
+		{
+			Endpoints:      []string{"rest://127.0.0.3:30100"},
+			DataCenterInfo: &discovery.DataCenterInfo{AvailableZone: "az1"},
+		},
+		{Endpoints: []string{"rest://127.0.0.4:30100"}},
+		{Endpoints: []string{}},
+		{
+			Endpoints:      []string{"rest://127.0.0.5:30100"},
+			DataCenterInfo: &discovery.DataCenterInfo{Region: "r2", AvailableZone: "az1"},
+		},
+	})
+	if assert.Len(t, groups, 4) {
+		assert.Equal(t, "az1", groups[0].az)
+		assert.Equal(t, []string{"127.0.0.1:30100", "127.0.0.3:30100"}, groups[0].addrs[ProtocolRest])
+		assert.Equal(t, []string{"127.0.0.1:30101"}, groups[0].addrs[ProtocolGrpc])
+		assert.Equal(t, []string{"127.0.0.2:30100"}, groups[1].addrs[ProtocolRest])
+		assert.Equal(t, []string{"127.0.0.4:30100"}, groups[2].addrs[ProtocolRest])
+		assert.Equal(t, "r2", groups[3].region)
+		assert.Equal(t, []string{"127.0.0.5:30100"}, groups[3].addrs[ProtocolRest])
+	}
+}
@@ -0,0 +1,53 @@
+package codec_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/cari/codec"
+	"github.com/go-chassis/cari/discovery"
+)
+
+func newInstance() *discovery.MicroServiceInstance {
+	return &discovery.MicroServiceInstance{
+		InstanceId:     "1",
+		ServiceId:      "2",
+		Endpoints:      []string{"rest://127.0.0.1:30100"},
+		Properties:     map[string]string{"k": "v"},
+		HealthCheck:    &discovery.HealthCheck{Mode: "push", Interval: 30, Times: 3},
+		DataCenterInfo: &discovery.DataCenterInfo{Name: "dc", Region: "cn", AvailableZone: "az1"},
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	for _, name := range []string{codec.NameJSON, codec.NameYAML, codec.NameProtobuf, codec.NameMsgpack} {
+		t.Run(name, func(t *testing.T) {
+			c := codec.Get(name)
+			data, err := c.Encode(newInstance())
+			assert.NoError(t, err)
+			instance := &discovery.MicroServiceInstance{}
+			assert.NoError(t, c.Decode(data, instance))
+			assert.Equal(t, newInstance(), instance)
+		})
+	}
+}
+
+func TestCodecs_fieldNames(t *testing.T) {
+	data, err := codec.YAML{}.Encode(newInstance())
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "instanceId: \"1\"")
+
+	var m map[string]any
+	data, err = codec.Msgpack{}.Encode(newInstance())
+	assert.NoError(t, err)
+	assert.NoError(t, codec.Msgpack{}.Decode(data, &m))
+	assert.Equal(t, "1", m["instanceId"])
+	assert.NotContains(t, m, "hostName", "should omit empty")
+}
+
+func TestProtobuf_notProtoMessage(t *testing.T) {
+	_, err := codec.Protobuf{}.Encode(map[string]string{})
+	assert.Equal(t, codec.ErrNotProtoMessage, err)
+	assert.Equal(t, codec.ErrNotProtoMessage, codec.Protobuf{}.Decode(nil, &map[string]string{}))
+}
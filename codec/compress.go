@@ -0,0 +1,186 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	CompressionGzip   = "gzip"
+	CompressionZstd   = "zstd"
+	CompressionSnappy = "snappy"
+
+	// MaxDecompressedSize the max size of the decompressed data, to defend against the decompression bombs
+	MaxDecompressedSize = 256 << 20
+)
+
+// Compressor compresses the encoded data
+type Compressor interface {
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+var compressors = make(map[string]Compressor)
+
+// RegisterCompressor registers the compressor by its name
+func RegisterCompressor(c Compressor) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	compressors[normalize(c.Name())] = c
+}
+
+// GetCompressor returns the compressor of the name, it returns nil if not found
+func GetCompressor(name string) Compressor {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return compressors[normalize(name)]
+}
+
+// Compressed returns a codec which compresses the data encoded by c
+func Compressed(c Codec, compressor Compressor) Codec {
+	return &compressedCodec{codec: c, compressor: compressor}
+}
+
+type compressedCodec struct {
+	codec      Codec
+	compressor Compressor
+}
+
+func (c *compressedCodec) Encode(v any) ([]byte, error) {
+	data, err := c.codec.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return c.compressor.Compress(data)
+}
+
+func (c *compressedCodec) Decode(data []byte, v any) error {
+	data, err := c.compressor.Decompress(data)
+	if err != nil {
+		return err
+	}
+	return c.codec.Decode(data, v)
+}
+
+// Gzip compresses by gzip in the Level, gzip.DefaultCompression if not set
+type Gzip struct {
+	Level int
+}
+
+func (Gzip) Name() string {
+	return CompressionGzip
+}
+
+func (g Gzip) Compress(data []byte) ([]byte, error) {
+	level := g.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (Gzip) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > MaxDecompressedSize {
+		return nil, fmt.Errorf("decompressed size exceeds the limit %d", MaxDecompressedSize)
+	}
+	return out, nil
+}
+
+// Zstd compresses by zstd in the default level
+type Zstd struct{}
+
+// the zstd encoder and decoder are created by the first use, they are safe for concurrent use
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdInitErr error
+)
+
+func initZstd() error {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdInitErr = zstd.NewWriter(nil)
+		if zstdInitErr != nil {
+			zstdInitErr = fmt.Errorf("new zstd encoder failed: %w", zstdInitErr)
+			return
+		}
+		zstdDecoder, zstdInitErr = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(MaxDecompressedSize))
+		if zstdInitErr != nil {
+			zstdInitErr = fmt.Errorf("new zstd decoder failed: %w", zstdInitErr)
+		}
+	})
+	return zstdInitErr
+}
+
+func (Zstd) Name() string {
+	return CompressionZstd
+}
+
+func (Zstd) Compress(data []byte) ([]byte, error) {
+	if err := initZstd(); err != nil {
+		return nil, err
+	}
+	return zstdEncoder.EncodeAll(data, nil), nil
+}
+
+func (Zstd) Decompress(data []byte) ([]byte, error) {
+	if err := initZstd(); err != nil {
+		return nil, err
+	}
+	return zstdDecoder.DecodeAll(data, nil)
+}
+
+// Snappy compresses by the snappy block format
+type Snappy struct{}
+
+func (Snappy) Name() string {
+	return CompressionSnappy
+}
+
+func (Snappy) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (Snappy) Decompress(data []byte) ([]byte, error) {
+	n, err := snappy.DecodedLen(data)
+	if err != nil {
+		return nil, err
+	}
+	if n > MaxDecompressedSize {
+		return nil, fmt.Errorf("decompressed size exceeds the limit %d", MaxDecompressedSize)
+	}
+	return snappy.Decode(nil, data)
+}
+
+func init() {
+	RegisterCompressor(Gzip{})
+	RegisterCompressor(Zstd{})
+	RegisterCompressor(Snappy{})
+}
@@ -0,0 +1,40 @@
+package codec_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/cari/codec"
+	"github.com/go-chassis/cari/discovery"
+)
+
+func TestCompressors(t *testing.T) {
+	data := []byte(strings.Repeat("rest://127.0.0.1:30100,", 100))
+	for _, name := range []string{codec.CompressionGzip, codec.CompressionZstd, codec.CompressionSnappy} {
+		t.Run(name, func(t *testing.T) {
+			c := codec.GetCompressor(name)
+			assert.Equal(t, name, c.Name())
+			compressed, err := c.Compress(data)
+			assert.NoError(t, err)
+			assert.Less(t, len(compressed), len(data))
+			decompressed, err := c.Decompress(compressed)
+			assert.NoError(t, err)
+			assert.Equal(t, data, decompressed)
+
+			_, err = c.Decompress([]byte("not compressed"))
+			assert.Error(t, err)
+		})
+	}
+	assert.Nil(t, codec.GetCompressor("lz4"))
+}
+
+func TestCompressed(t *testing.T) {
+	c := codec.Compressed(codec.Protobuf{}, codec.Zstd{})
+	data, err := c.Encode(newInstance())
+	assert.NoError(t, err)
+	instance := &discovery.MicroServiceInstance{}
+	assert.NoError(t, c.Decode(data, instance))
+	assert.Equal(t, newInstance(), instance)
+}
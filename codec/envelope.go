@@ -0,0 +1,172 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// envelope layout, the integers are in big endian:
+// magic(4) | format version(1) | schema version(2) | codec name len(1) | codec name |
+// compression name len(1) | compression name | payload | crc32 of all the above(4)
+const (
+	envelopeFormatVersion = 1
+	envelopeMinSize       = 4 + 1 + 2 + 1 + 1 + 4
+)
+
+// the text, json and protobuf data never start with a NUL byte
+var envelopeMagic = []byte{0x00, 'C', 'R', 'E'}
+
+var (
+	// ErrChecksumMismatch is returned when the enveloped data is corrupted
+	ErrChecksumMismatch = errors.New("envelope checksum mismatch")
+	// ErrBadEnvelope is returned when the enveloped data can not be parsed
+	ErrBadEnvelope = errors.New("bad envelope")
+)
+
+// Envelope is the header and payload of the enveloped data
+type Envelope struct {
+	SchemaVersion uint16
+	Codec         string // name of the codec which encodes the payload
+	Compression   string // name of the compressor which compresses the payload, empty if not compressed
+	Payload       []byte
+}
+
+// IsEnveloped reports whether data starts with the envelope magic
+func IsEnveloped(data []byte) bool {
+	return bytes.HasPrefix(data, envelopeMagic)
+}
+
+// Seal returns the enveloped data of e
+func Seal(e *Envelope) ([]byte, error) {
+	if len(e.Codec) > 255 || len(e.Compression) > 255 {
+		return nil, fmt.Errorf("codec or compression name is too long")
+	}
+	buf := make([]byte, 0, envelopeMinSize+len(e.Codec)+len(e.Compression)+len(e.Payload))
+	buf = append(buf, envelopeMagic...)
+	buf = append(buf, envelopeFormatVersion)
+	buf = append(buf, byte(e.SchemaVersion>>8), byte(e.SchemaVersion))
+	buf = append(buf, byte(len(e.Codec)))
+	buf = append(buf, e.Codec...)
+	buf = append(buf, byte(len(e.Compression)))
+	buf = append(buf, e.Compression...)
+	buf = append(buf, e.Payload...)
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, crc32.ChecksumIEEE(buf))
+	return append(buf, sum...), nil
+}
+
+// Open parses the enveloped data and verifies its checksum
+func Open(data []byte) (*Envelope, error) {
+	if !IsEnveloped(data) || len(data) < envelopeMinSize {
+		return nil, ErrBadEnvelope
+	}
+	body, sum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, ErrChecksumMismatch
+	}
+	rest := body[len(envelopeMagic):]
+	if rest[0] != envelopeFormatVersion {
+		return nil, fmt.Errorf("%w: unsupported format version %d", ErrBadEnvelope, rest[0])
+	}
+	e := &Envelope{SchemaVersion: binary.BigEndian.Uint16(rest[1:3])}
+	rest = rest[3:]
+	var ok bool
+	if e.Codec, rest, ok = readName(rest); !ok {
+		return nil, ErrBadEnvelope
+	}
+	if e.Compression, rest, ok = readName(rest); !ok {
+		return nil, ErrBadEnvelope
+	}
+	e.Payload = rest
+	return e, nil
+}
+
+func readName(data []byte) (string, []byte, bool) {
+	if len(data) < 1 || len(data) < 1+int(data[0]) {
+		return "", nil, false
+	}
+	n := int(data[0])
+	return string(data[1 : 1+n]), data[1+n:], true
+}
+
+// EnvelopeOptions configures the codec returned by NewEnvelopeCodec
+type EnvelopeOptions struct {
+	// Codec name of the registered codec to encode the payload, e.g. json
+	Codec string
+	// SchemaVersion the version of the payload schema, it is up to the user
+	SchemaVersion uint16
+	// Compressor compresses the payload, not compressed if not set
+	Compressor Compressor
+}
+
+// NewEnvelopeCodec returns a codec which wraps the encoded payload in a versioned envelope with checksum.
+// It decodes the enveloped data by the codec and compressor named in the envelope,
+// and decodes the data without envelope by the codec of opts as is, to interoperate with the old peers
+func NewEnvelopeCodec(opts EnvelopeOptions) (*EnvelopeCodec, error) {
+	c, err := Lookup(opts.Codec)
+	if err != nil {
+		return nil, fmt.Errorf("codec %s: %w", opts.Codec, err)
+	}
+	return &EnvelopeCodec{opts: opts, codec: c}, nil
+}
+
+// MustNewEnvelopeCodec is like NewEnvelopeCodec, but panics if the codec of opts is not registered,
+// it is used to initialize the package level codecs
+func MustNewEnvelopeCodec(opts EnvelopeOptions) *EnvelopeCodec {
+	c, err := NewEnvelopeCodec(opts)
+	if err != nil {
+		panic(fmt.Sprintf("new envelope codec failed: %s", err))
+	}
+	return c
+}
+
+// EnvelopeCodec is the codec returned by NewEnvelopeCodec
+type EnvelopeCodec struct {
+	opts  EnvelopeOptions
+	codec Codec
+}
+
+func (c *EnvelopeCodec) Encode(v any) ([]byte, error) {
+	payload, err := c.codec.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	e := &Envelope{SchemaVersion: c.opts.SchemaVersion, Codec: c.opts.Codec, Payload: payload}
+	if c.opts.Compressor != nil {
+		e.Compression = c.opts.Compressor.Name()
+		e.Payload, err = c.opts.Compressor.Compress(payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return Seal(e)
+}
+
+func (c *EnvelopeCodec) Decode(data []byte, v any) error {
+	if !IsEnveloped(data) {
+		return c.codec.Decode(data, v)
+	}
+	e, err := Open(data)
+	if err != nil {
+		return err
+	}
+	payload := e.Payload
+	if len(e.Compression) > 0 {
+		compressor := GetCompressor(e.Compression)
+		if compressor == nil {
+			return fmt.Errorf("compressor %s: %w", e.Compression, ErrNotFound)
+		}
+		payload, err = compressor.Decompress(payload)
+		if err != nil {
+			return err
+		}
+	}
+	payloadCodec, err := Lookup(e.Codec)
+	if err != nil {
+		return fmt.Errorf("codec %s: %w", e.Codec, err)
+	}
+	return payloadCodec.Decode(payload, v)
+}
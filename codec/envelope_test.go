@@ -0,0 +1,74 @@
+package codec_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/cari/codec"
+	"github.com/go-chassis/cari/discovery"
+)
+
+func TestSeal(t *testing.T) {
+	data, err := codec.Seal(&codec.Envelope{SchemaVersion: 3, Codec: "json", Compression: "gzip", Payload: []byte("{}")})
+	assert.NoError(t, err)
+	assert.True(t, codec.IsEnveloped(data))
+
+	e, err := codec.Open(data)
+	assert.NoError(t, err)
+	assert.Equal(t, &codec.Envelope{SchemaVersion: 3, Codec: "json", Compression: "gzip", Payload: []byte("{}")}, e)
+
+	t.Run("corrupted, should return checksum mismatch", func(t *testing.T) {
+		corrupted := append([]byte{}, data...)
+		corrupted[len(corrupted)-5] = '['
+		_, err := codec.Open(corrupted)
+		assert.Equal(t, codec.ErrChecksumMismatch, err)
+	})
+	t.Run("truncated, should return bad envelope", func(t *testing.T) {
+		_, err := codec.Open(data[:6])
+		assert.Equal(t, codec.ErrBadEnvelope, err)
+		_, err = codec.Open([]byte("{}"))
+		assert.Equal(t, codec.ErrBadEnvelope, err)
+	})
+}
+
+func TestEnvelopeCodec(t *testing.T) {
+	_, err := codec.NewEnvelopeCodec(codec.EnvelopeOptions{Codec: "xml"})
+	assert.ErrorIs(t, err, codec.ErrNotFound)
+
+	protobufCodec, err := codec.NewEnvelopeCodec(codec.EnvelopeOptions{
+		Codec:         codec.NameProtobuf,
+		SchemaVersion: 1,
+		Compressor:    codec.Snappy{},
+	})
+	assert.NoError(t, err)
+	data, err := protobufCodec.Encode(newInstance())
+	assert.NoError(t, err)
+	e, err := codec.Open(data)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(1), e.SchemaVersion)
+	assert.Equal(t, codec.NameProtobuf, e.Codec)
+	assert.Equal(t, codec.CompressionSnappy, e.Compression)
+
+	jsonCodec, err := codec.NewEnvelopeCodec(codec.EnvelopeOptions{Codec: codec.NameJSON})
+	assert.NoError(t, err)
+	t.Run("enveloped by another codec, should decode by the codec in envelope", func(t *testing.T) {
+		instance := &discovery.MicroServiceInstance{}
+		assert.NoError(t, jsonCodec.Decode(data, instance))
+		assert.Equal(t, newInstance(), instance)
+	})
+	t.Run("not enveloped, should decode by the codec as is", func(t *testing.T) {
+		raw, err := codec.JSON{}.Encode(newInstance())
+		assert.NoError(t, err)
+		instance := &discovery.MicroServiceInstance{}
+		assert.NoError(t, jsonCodec.Decode(raw, instance))
+		assert.Equal(t, newInstance(), instance)
+	})
+}
+
+func TestMustNewEnvelopeCodec(t *testing.T) {
+	assert.NotNil(t, codec.MustNewEnvelopeCodec(codec.EnvelopeOptions{Codec: codec.NameJSON}))
+	assert.Panics(t, func() {
+		codec.MustNewEnvelopeCodec(codec.EnvelopeOptions{Codec: "not-exist"})
+	})
+}
@@ -0,0 +1,23 @@
+package codec
+
+import "encoding/json"
+
+const (
+	NameJSON = "json"
+	MIMEJSON = "application/json"
+)
+
+// JSON encodes and decodes by encoding/json
+type JSON struct{}
+
+func (JSON) Encode(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSON) Decode(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func init() {
+	Register(NameJSON, JSON{}, MIMEJSON)
+}
@@ -0,0 +1,35 @@
+package codec
+
+import (
+	"bytes"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	NameMsgpack = "msgpack"
+	MIMEMsgpack = "application/msgpack"
+)
+
+// Msgpack encodes and decodes by the json tags, so the field names are the same as JSON
+type Msgpack struct{}
+
+func (Msgpack) Encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (Msgpack) Decode(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+func init() {
+	Register(NameMsgpack, Msgpack{}, MIMEMsgpack, "application/x-msgpack")
+}
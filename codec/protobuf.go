@@ -0,0 +1,38 @@
+package codec
+
+import (
+	"errors"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+const (
+	NameProtobuf = "protobuf"
+	MIMEProtobuf = "application/x-protobuf"
+)
+
+// ErrNotProtoMessage is returned when the value to encode or decode is not a proto.Message
+var ErrNotProtoMessage = errors.New("value is not a proto.Message")
+
+// Protobuf encodes and decodes the proto.Message by gogo protobuf, e.g. the discovery types
+type Protobuf struct{}
+
+func (Protobuf) Encode(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrNotProtoMessage
+	}
+	return proto.Marshal(m)
+}
+
+func (Protobuf) Decode(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return ErrNotProtoMessage
+	}
+	return proto.Unmarshal(data, m)
+}
+
+func init() {
+	Register(NameProtobuf, Protobuf{}, MIMEProtobuf, "application/protobuf")
+}
@@ -0,0 +1,104 @@
+package codec
+
+import (
+	"errors"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when no codec is registered for the name or MIME type
+var ErrNotFound = errors.New("codec not found")
+
+var (
+	mutex  sync.RWMutex
+	codecs = make(map[string]Codec) // keyed by the names and MIME types, in lower case
+)
+
+// Register registers the codec by name and the MIME types it handles,
+// a codec registered later replaces the former one of the same name or MIME type
+func Register(name string, c Codec, mimeTypes ...string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	codecs[strings.ToLower(name)] = c
+	for _, t := range mimeTypes {
+		codecs[normalize(t)] = c
+	}
+}
+
+// Get returns the codec of the name or the MIME type, the MIME parameters are ignored,
+// e.g. "json" and "application/json; charset=utf-8" both return the json codec.
+// It returns nil if not found
+func Get(nameOrMIME string) Codec {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return codecs[normalize(nameOrMIME)]
+}
+
+// Lookup is like Get but returns ErrNotFound if not found
+func Lookup(nameOrMIME string) (Codec, error) {
+	c := Get(nameOrMIME)
+	if c == nil {
+		return nil, ErrNotFound
+	}
+	return c, nil
+}
+
+// Negotiate returns the MIME type and codec of the most preferred registered media range of
+// an Accept header, "*/*" or an empty header gets the json codec.
+// It returns ErrNotFound if none of the media ranges is registered
+func Negotiate(accept string) (string, Codec, error) {
+	if len(strings.TrimSpace(accept)) == 0 {
+		return MIMEJSON, Get(MIMEJSON), nil
+	}
+	ranges := parseAccept(accept)
+	for _, r := range ranges {
+		if r.mimeType == "*/*" || r.mimeType == "application/*" {
+			return MIMEJSON, Get(MIMEJSON), nil
+		}
+		if c := Get(r.mimeType); c != nil {
+			return r.mimeType, c, nil
+		}
+	}
+	return "", nil, ErrNotFound
+}
+
+type mediaRange struct {
+	mimeType string
+	q        float64
+}
+
+// parseAccept returns the media ranges with q > 0, ordered by q descending and then the order in header
+func parseAccept(accept string) []mediaRange {
+	ranges := make([]mediaRange, 0)
+	for _, part := range strings.Split(accept, ",") {
+		mimeType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		ranges = append(ranges, mediaRange{mimeType: mimeType, q: q})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+	return ranges
+}
+
+func normalize(nameOrMIME string) string {
+	if i := strings.Index(nameOrMIME, ";"); i >= 0 {
+		nameOrMIME = nameOrMIME[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(nameOrMIME))
+}
@@ -0,0 +1,64 @@
+package codec_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/cari/codec"
+)
+
+type upperJSON struct {
+	codec.JSON
+}
+
+func TestGet(t *testing.T) {
+	assert.Equal(t, codec.JSON{}, codec.Get("json"))
+	assert.Equal(t, codec.JSON{}, codec.Get("application/json"))
+	assert.Equal(t, codec.JSON{}, codec.Get("Application/JSON; charset=utf-8"))
+	assert.Equal(t, codec.YAML{}, codec.Get("text/yaml"))
+	assert.Equal(t, codec.Protobuf{}, codec.Get("application/x-protobuf"))
+	assert.Equal(t, codec.Msgpack{}, codec.Get("msgpack"))
+	assert.Nil(t, codec.Get("application/xml"))
+
+	_, err := codec.Lookup("application/xml")
+	assert.Equal(t, codec.ErrNotFound, err)
+	c, err := codec.Lookup("yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, codec.YAML{}, c)
+}
+
+func TestRegister(t *testing.T) {
+	codec.Register("upper-json", upperJSON{}, "application/vnd.upper+json")
+	assert.Equal(t, upperJSON{}, codec.Get("upper-json"))
+	assert.Equal(t, upperJSON{}, codec.Get("application/vnd.upper+json"))
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept   string
+		wantMIME string
+		wantErr  bool
+	}{
+		{"", codec.MIMEJSON, false},
+		{"*/*", codec.MIMEJSON, false},
+		{"application/yaml", codec.MIMEYAML, false},
+		{"application/xml, application/msgpack", codec.MIMEMsgpack, false},
+		{"application/json;q=0.5, application/x-protobuf", codec.MIMEProtobuf, false},
+		{"application/json;q=0.5, application/x-protobuf;q=0", codec.MIMEJSON, false},
+		{"text/html, application/*;q=0.1", codec.MIMEJSON, false},
+		{"application/xml", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			mimeType, c, err := codec.Negotiate(tt.accept)
+			if tt.wantErr {
+				assert.Equal(t, codec.ErrNotFound, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantMIME, mimeType)
+			assert.Equal(t, codec.Get(tt.wantMIME), c)
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package codec
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/go-chassis/cari/security"
+)
+
+// SecureTag marks the string or []byte fields to encrypt by the codec returned by Encrypted,
+// e.g. Password string `json:"password" secure:"true"`
+const SecureTag = "secure"
+
+// Encrypted returns a codec which encrypts the fields marked by SecureTag with cipher before encoding,
+// and decrypts them after decoding. The fields of the nested structs, pointers, slices, arrays, map values
+// and interfaces are included, the value to encode is copied, so the caller's value is not changed.
+// To decrypt the value in an interface, it must be set to a pointer before decoding, e.g. &config.KVDoc{}.
+// Empty fields are neither encrypted nor decrypted
+func Encrypted(c Codec, cipher security.Cipher) Codec {
+	return &encryptedCodec{codec: c, cipher: cipher}
+}
+
+type encryptedCodec struct {
+	codec  Codec
+	cipher security.Cipher
+}
+
+func (c *encryptedCodec) Encode(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || !hasSecureField(rv.Type()) {
+		return c.codec.Encode(v)
+	}
+	encrypted, err := c.copyAndEncrypt(rv)
+	if err != nil {
+		return nil, err
+	}
+	return c.codec.Encode(encrypted.Interface())
+}
+
+func (c *encryptedCodec) Decode(data []byte, v any) error {
+	if err := c.codec.Decode(data, v); err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || !hasSecureField(rv.Type()) {
+		return nil
+	}
+	return c.decrypt(rv)
+}
+
+func (c *encryptedCodec) copyAndEncrypt(v reflect.Value) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v, nil
+		}
+		elem, err := c.copyAndEncrypt(v.Elem())
+		if err != nil {
+			return v, err
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(elem)
+		return p, nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return v, nil
+		}
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			elem, err := c.copyAndEncrypt(v.Index(i))
+			if err != nil {
+				return v, err
+			}
+			s.Index(i).Set(elem)
+		}
+		return s, nil
+	case reflect.Array:
+		a := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			elem, err := c.copyAndEncrypt(v.Index(i))
+			if err != nil {
+				return v, err
+			}
+			a.Index(i).Set(elem)
+		}
+		return a, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return v, nil
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			elem, err := c.copyAndEncrypt(iter.Value())
+			if err != nil {
+				return v, err
+			}
+			m.SetMapIndex(iter.Key(), elem)
+		}
+		return m, nil
+	case reflect.Interface:
+		if v.IsNil() || !hasSecureField(v.Elem().Type()) {
+			return v, nil
+		}
+		elem, err := c.copyAndEncrypt(v.Elem())
+		if err != nil {
+			return v, err
+		}
+		i := reflect.New(v.Type()).Elem()
+		i.Set(elem)
+		return i, nil
+	case reflect.Struct:
+		s := reflect.New(v.Type()).Elem()
+		s.Set(v)
+		for i := 0; i < s.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !s.Field(i).CanSet() {
+				continue
+			}
+			if isSecureField(field) {
+				if err := c.transform(s.Field(i), c.cipher.Encrypt); err != nil {
+					return v, fmt.Errorf("encrypt %s: %w", field.Name, err)
+				}
+				continue
+			}
+			if !hasSecureField(field.Type) {
+				continue
+			}
+			elem, err := c.copyAndEncrypt(s.Field(i))
+			if err != nil {
+				return v, err
+			}
+			s.Field(i).Set(elem)
+		}
+		return s, nil
+	}
+	return v, nil
+}
+
+func (c *encryptedCodec) decrypt(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return c.decrypt(v.Elem())
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := c.decrypt(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			// the map value is not addressable, decrypt a copy and set it back
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			if err := c.decrypt(elem); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	case reflect.Interface:
+		if v.IsNil() || !hasSecureField(v.Elem().Type()) {
+			return nil
+		}
+		if v.Elem().Kind() == reflect.Ptr {
+			return c.decrypt(v.Elem())
+		}
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		if err := c.decrypt(elem); err != nil {
+			return err
+		}
+		if v.CanSet() {
+			v.Set(elem)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !v.Field(i).CanSet() {
+				continue
+			}
+			if isSecureField(field) {
+				if err := c.transform(v.Field(i), c.cipher.Decrypt); err != nil {
+					return fmt.Errorf("decrypt %s: %w", field.Name, err)
+				}
+				continue
+			}
+			if hasSecureField(field.Type) {
+				if err := c.decrypt(v.Field(i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// transform sets the string or []byte field by fn
+func (c *encryptedCodec) transform(v reflect.Value, fn func(string) (string, error)) error {
+	switch {
+	case v.Kind() == reflect.String:
+		if v.Len() == 0 {
+			return nil
+		}
+		s, err := fn(v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		if v.Len() == 0 {
+			return nil
+		}
+		s, err := fn(string(v.Bytes()))
+		if err != nil {
+			return err
+		}
+		v.SetBytes([]byte(s))
+	default:
+		return fmt.Errorf("%s tag is only supported on string and []byte, not %s", SecureTag, v.Type())
+	}
+	return nil
+}
+
+func isSecureField(field reflect.StructField) bool {
+	return field.Tag.Get(SecureTag) == "true"
+}
+
+var secureTypes sync.Map // reflect.Type -> bool
+
+// hasSecureField reports whether there is any field marked by SecureTag reachable from t
+func hasSecureField(t reflect.Type) bool {
+	if has, ok := secureTypes.Load(t); ok {
+		return has.(bool)
+	}
+	has := findSecureField(t, make(map[reflect.Type]bool))
+	secureTypes.Store(t, has)
+	return has
+}
+
+func findSecureField(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return findSecureField(t.Elem(), visiting)
+	case reflect.Interface:
+		return true // the dynamic value is checked while walking
+	case reflect.Struct:
+		if visiting[t] {
+			return false // recursive type, it is checked by the outer call
+		}
+		visiting[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if isSecureField(field) || findSecureField(field.Type, visiting) {
+				return true
+			}
+		}
+	}
+	return false
+}
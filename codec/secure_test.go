@@ -0,0 +1,124 @@
+package codec_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/cari/codec"
+	"github.com/go-chassis/cari/config"
+	"github.com/go-chassis/cari/rbac"
+)
+
+// base64Cipher is not secure, just to make the encrypted value recognizable
+type base64Cipher struct{}
+
+func (base64Cipher) Encrypt(src string) (string, error) {
+	return base64.StdEncoding.EncodeToString([]byte(src)), nil
+}
+
+func (base64Cipher) Decrypt(src string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(src)
+	return string(b), err
+}
+
+type failedCipher struct{}
+
+func (failedCipher) Encrypt(string) (string, error) { return "", errors.New("no key") }
+func (failedCipher) Decrypt(string) (string, error) { return "", errors.New("no key") }
+
+type secret struct {
+	Token []byte   `json:"token" secure:"true"`
+	Plain string   `json:"plain"`
+	Next  *secret  `json:"next,omitempty"`
+	List  []secret `json:"list,omitempty"`
+}
+
+func TestEncrypted(t *testing.T) {
+	c := codec.Encrypted(codec.JSON{}, base64Cipher{})
+
+	t.Run("encode account, should encrypt passwords and not change the account", func(t *testing.T) {
+		a := &rbac.Account{Name: "root", Password: "Pwd@1234"}
+		data, err := c.Encode(a)
+		assert.NoError(t, err)
+		assert.Equal(t, "Pwd@1234", a.Password)
+
+		raw := &rbac.Account{}
+		assert.NoError(t, codec.JSON{}.Decode(data, raw))
+		assert.Equal(t, "root", raw.Name)
+		assert.Equal(t, "UHdkQDEyMzQ=", raw.Password)
+		assert.Empty(t, raw.CurrentPassword, "empty field should not be encrypted")
+
+		got := &rbac.Account{}
+		assert.NoError(t, c.Decode(data, got))
+		assert.Equal(t, a, got)
+	})
+	t.Run("encode kv doc by value, should encrypt value", func(t *testing.T) {
+		data, err := c.Encode(config.KVDoc{Key: "db.password", Value: "secret"})
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), "c2VjcmV0")
+		got := &config.KVDoc{}
+		assert.NoError(t, c.Decode(data, got))
+		assert.Equal(t, "secret", got.Value)
+	})
+	t.Run("nested fields, should encrypt all of them", func(t *testing.T) {
+		s := &secret{Token: []byte("a"), Plain: "p", Next: &secret{Token: []byte("b")}, List: []secret{{Token: []byte("c")}}}
+		data, err := c.Encode(s)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("b"), s.Next.Token)
+		assert.Equal(t, []byte("c"), s.List[0].Token)
+
+		raw := &secret{}
+		assert.NoError(t, codec.JSON{}.Decode(data, raw))
+		assert.Equal(t, "p", raw.Plain)
+		assert.Equal(t, []byte("YQ=="), raw.Token)
+		assert.Equal(t, []byte("Yg=="), raw.Next.Token)
+		assert.Equal(t, []byte("Yw=="), raw.List[0].Token)
+
+		got := &secret{}
+		assert.NoError(t, c.Decode(data, got))
+		assert.Equal(t, s, got)
+	})
+	t.Run("map values and interfaces, should encrypt all of them", func(t *testing.T) {
+		type docs struct {
+			ByKey map[string]*config.KVDoc `json:"byKey"`
+			Any   interface{}              `json:"any"`
+		}
+		d := &docs{
+			ByKey: map[string]*config.KVDoc{"db": {Key: "db.password", Value: "secret"}},
+			Any:   config.KVDoc{Key: "mq.password", Value: "secret2"},
+		}
+		data, err := c.Encode(d)
+		assert.NoError(t, err)
+		assert.Equal(t, "secret", d.ByKey["db"].Value)
+		assert.Equal(t, "secret2", d.Any.(config.KVDoc).Value)
+		assert.NotContains(t, string(data), `"secret"`)
+		assert.NotContains(t, string(data), `"secret2"`)
+		assert.Contains(t, string(data), "c2VjcmV0Mg==")
+
+		got := &docs{Any: &config.KVDoc{}}
+		assert.NoError(t, c.Decode(data, got))
+		assert.Equal(t, "secret", got.ByKey["db"].Value)
+		assert.Equal(t, "secret2", got.Any.(*config.KVDoc).Value)
+
+		m := map[string]config.KVDoc{}
+		data, err = c.Encode(map[string]config.KVDoc{"db": {Value: "secret"}})
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), `"secret"`)
+		assert.NoError(t, c.Decode(data, &m))
+		assert.Equal(t, "secret", m["db"].Value)
+	})
+	t.Run("no secure field, should encode as is", func(t *testing.T) {
+		data, err := c.Encode(map[string]string{"a": "b"})
+		assert.NoError(t, err)
+		assert.Equal(t, `{"a":"b"}`, string(data))
+	})
+	t.Run("cipher failed, should return error", func(t *testing.T) {
+		c := codec.Encrypted(codec.JSON{}, failedCipher{})
+		_, err := c.Encode(&rbac.Account{Password: "Pwd@1234"})
+		assert.Error(t, err)
+		assert.Error(t, c.Decode([]byte(`{"password":"x"}`), &rbac.Account{}))
+	})
+}
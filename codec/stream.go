@@ -0,0 +1,144 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+const (
+	NameJSONLines = "jsonl"
+	MIMEJSONLines = "application/x-ndjson"
+
+	NameProtobufDelimited = "protobuf-delimited"
+	MIMEProtobufDelimited = "application/x-protobuf-delimited"
+
+	defaultMaxMessageSize = 64 << 20
+)
+
+// Encoder writes values to a stream one by one
+type Encoder interface {
+	Encode(v any) error
+}
+
+// Decoder reads values from a stream one by one, it returns io.EOF when there is no more value
+type Decoder interface {
+	Decode(v any) error
+}
+
+// StreamCodec encodes and decodes a sequence of values without buffering them all in memory,
+// e.g. export the instances of a registry snapshot one by one
+type StreamCodec interface {
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+var streamCodecs = make(map[string]StreamCodec) // keyed by the names and MIME types, in lower case
+
+// RegisterStream registers the stream codec by name and the MIME types it handles, like Register
+func RegisterStream(name string, c StreamCodec, mimeTypes ...string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	streamCodecs[normalize(name)] = c
+	for _, t := range mimeTypes {
+		streamCodecs[normalize(t)] = c
+	}
+}
+
+// GetStream returns the stream codec of the name or the MIME type, it returns nil if not found
+func GetStream(nameOrMIME string) StreamCodec {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return streamCodecs[normalize(nameOrMIME)]
+}
+
+// JSONLines encodes each value as a line of json
+type JSONLines struct{}
+
+func (JSONLines) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+func (JSONLines) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
+
+// ProtobufDelimited encodes each proto.Message with a varint prefix of its size
+type ProtobufDelimited struct {
+	// MaxMessageSize the max size of a message to decode, default 64MB
+	MaxMessageSize int
+}
+
+func (ProtobufDelimited) NewEncoder(w io.Writer) Encoder {
+	return &protobufDelimitedEncoder{w: w}
+}
+
+func (c ProtobufDelimited) NewDecoder(r io.Reader) Decoder {
+	maxSize := c.MaxMessageSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxMessageSize
+	}
+	return &protobufDelimitedDecoder{r: bufio.NewReader(r), maxSize: maxSize}
+}
+
+type protobufDelimitedEncoder struct {
+	w      io.Writer
+	prefix [binary.MaxVarintLen64]byte
+}
+
+func (e *protobufDelimitedEncoder) Encode(v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return ErrNotProtoMessage
+	}
+	data, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	n := binary.PutUvarint(e.prefix[:], uint64(len(data)))
+	if _, err := e.w.Write(e.prefix[:n]); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+type protobufDelimitedDecoder struct {
+	r       *bufio.Reader
+	maxSize int
+	buf     []byte
+}
+
+func (d *protobufDelimitedDecoder) Decode(v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return ErrNotProtoMessage
+	}
+	size, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return err // io.EOF if no more message
+	}
+	if size > uint64(d.maxSize) {
+		return fmt.Errorf("message size %d exceeds the limit %d", size, d.maxSize)
+	}
+	if cap(d.buf) < int(size) {
+		d.buf = make([]byte, size)
+	}
+	d.buf = d.buf[:size]
+	if _, err := io.ReadFull(d.r, d.buf); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return proto.Unmarshal(d.buf, m)
+}
+
+func init() {
+	RegisterStream(NameJSONLines, JSONLines{}, MIMEJSONLines, "application/jsonl")
+	RegisterStream(NameProtobufDelimited, ProtobufDelimited{}, MIMEProtobufDelimited)
+}
@@ -0,0 +1,69 @@
+package codec_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/cari/codec"
+	"github.com/go-chassis/cari/discovery"
+)
+
+func TestStreamCodecs(t *testing.T) {
+	for _, name := range []string{codec.NameJSONLines, codec.NameProtobufDelimited} {
+		t.Run(name, func(t *testing.T) {
+			c := codec.GetStream(name)
+			var buf bytes.Buffer
+			enc := c.NewEncoder(&buf)
+			for i := 0; i < 3; i++ {
+				assert.NoError(t, enc.Encode(newInstance()))
+			}
+
+			dec := c.NewDecoder(&buf)
+			cnt := 0
+			for {
+				instance := &discovery.MicroServiceInstance{}
+				err := dec.Decode(instance)
+				if err == io.EOF {
+					break
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, newInstance(), instance)
+				cnt++
+			}
+			assert.Equal(t, 3, cnt)
+		})
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	enc := codec.JSONLines{}.NewEncoder(&buf)
+	assert.NoError(t, enc.Encode(&discovery.DataCenterInfo{Name: "a"}))
+	assert.NoError(t, enc.Encode(&discovery.DataCenterInfo{Name: "b"}))
+	assert.Equal(t, "{\"name\":\"a\"}\n{\"name\":\"b\"}\n", buf.String())
+	assert.Equal(t, codec.JSONLines{}, codec.GetStream("application/x-ndjson"))
+}
+
+func TestProtobufDelimited(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, codec.ProtobufDelimited{}.NewEncoder(&buf).Encode(newInstance()))
+	data := buf.Bytes()
+
+	t.Run("not proto message, should return error", func(t *testing.T) {
+		assert.Equal(t, codec.ErrNotProtoMessage, codec.ProtobufDelimited{}.NewEncoder(&buf).Encode("s"))
+	})
+	t.Run("truncated message, should return unexpected EOF", func(t *testing.T) {
+		dec := codec.ProtobufDelimited{}.NewDecoder(bytes.NewReader(data[:len(data)-1]))
+		assert.Equal(t, io.ErrUnexpectedEOF, dec.Decode(&discovery.MicroServiceInstance{}))
+	})
+	t.Run("message too large, should return error", func(t *testing.T) {
+		dec := codec.ProtobufDelimited{MaxMessageSize: 8}.NewDecoder(bytes.NewReader(data))
+		err := dec.Decode(&discovery.MicroServiceInstance{})
+		assert.Error(t, err)
+		assert.True(t, strings.Contains(err.Error(), "exceeds"))
+	})
+}
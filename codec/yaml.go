@@ -0,0 +1,23 @@
+package codec
+
+import "sigs.k8s.io/yaml"
+
+const (
+	NameYAML = "yaml"
+	MIMEYAML = "application/yaml"
+)
+
+// YAML encodes and decodes by the json tags, it is converted to and from json
+type YAML struct{}
+
+func (YAML) Encode(v any) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+func (YAML) Decode(data []byte, v any) error {
+	return yaml.Unmarshal(data, v)
+}
+
+func init() {
+	Register(NameYAML, YAML{}, MIMEYAML, "application/x-yaml", "text/yaml")
+}
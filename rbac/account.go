@@ -44,12 +44,12 @@ type AccountResponse struct {
 type Account struct {
 	ID       string `json:"id,omitempty"`
 	Name     string `json:"name,omitempty"`
-	Password string `json:"password,omitempty"`
+	Password string `json:"password,omitempty" secure:"true"`
 	//Deprecated
 	Role                string   `json:"role,omitempty"`
 	Roles               []string `json:"roles,omitempty"`
 	TokenExpirationTime string   `json:"tokenExpirationTime,omitempty" bson:"token_expiration_time"`
-	CurrentPassword     string   `json:"currentPassword,omitempty" bson:"current_password"`
+	CurrentPassword     string   `json:"currentPassword,omitempty" bson:"current_password" secure:"true"`
 	Status              string   `json:"status,omitempty"`
 	CreateTime          string   `json:"createTime,omitempty"`
 	UpdateTime          string   `json:"updateTime,omitempty"`
@@ -78,7 +78,7 @@ type Token struct {
 
 type AuthUser struct {
 	Username string `json:"name,omitempty"`
-	Password string `json:"password,omitempty"`
+	Password string `json:"password,omitempty" secure:"true"`
 }
 
 func (a *Account) HasAdminRole() bool {
@@ -2,43 +2,397 @@ package rbac
 
 import (
 	"errors"
-	"github.com/karlseguin/ccache/v2"
+	"reflect"
+	"sync"
 	"time"
+
+	"github.com/karlseguin/ccache/v2"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
-	DefaultTTL = 1 * time.Hour
+	DefaultTTL         = 1 * time.Hour
+	DefaultNegativeTTL = 10 * time.Second
 )
 
 var ErrInvalidPerms = errors.New("perms is invalid")
 var ErrEmptyPerms = errors.New("perms is empty")
 
+// FindPerms returns all the roles from the persistence
 type FindPerms func() ([]*Role, error)
 type PersistPerms func(r *Role) error
 
-var permsCache = ccache.New(ccache.Configure())
+var (
+	roleCacheMutex sync.RWMutex
+	roleCache      = NewRoleCache(RoleCacheOptions{})
+)
+
+// RoleCacheOptions configures a RoleCache, zero values use the defaults
+type RoleCacheOptions struct {
+	// TTL of the cached roles, default DefaultTTL. an expired role is reloaded by FindPerms when it is read,
+	// or still served if FindPerms is not set or fails
+	TTL time.Duration
+	// NegativeTTL how long a role not found by FindPerms is remembered, default DefaultNegativeTTL
+	NegativeTTL time.Duration
+	// RefreshInterval reloads all the roles by FindPerms in background, disabled if 0 or FindPerms is not set
+	RefreshInterval time.Duration
+	// MaxSize the max count of the cached roles, default is the ccache default
+	MaxSize int64
+	// FindPerms is used to read through on cache miss and refresh the cache
+	FindPerms FindPerms
+}
+
+// absentRole marks a role not found by FindPerms, to not reload it on every read
+type absentRole struct{}
 
-// WritePerms save cache
+// RoleCache caches the roles to read the effective perms of them
+type RoleCache struct {
+	opts   RoleCacheOptions
+	cache  *ccache.Cache
+	loader singleflight.Group
+
+	mutex  sync.RWMutex
+	hooks  map[int]func(roleName string)
+	nextID int
+
+	// written are the roles written to cache but not found by FindPerms yet, e.g. they are being persisted,
+	// refresh keeps them until they expire
+	writtenMutex sync.Mutex
+	written      map[string]bool
+
+	quit     chan struct{}
+	quitOnce sync.Once
+	// the stopped ccache can not be used, the accesses after Close do nothing
+	closeMutex sync.RWMutex
+	closed     bool
+}
+
+// NewRoleCache returns a RoleCache, it starts to refresh in background if RefreshInterval is set
+func NewRoleCache(opts RoleCacheOptions) *RoleCache {
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+	if opts.NegativeTTL <= 0 {
+		opts.NegativeTTL = DefaultNegativeTTL
+	}
+	conf := ccache.Configure()
+	if opts.MaxSize > 0 {
+		conf = conf.MaxSize(opts.MaxSize)
+	}
+	c := &RoleCache{
+		opts:    opts,
+		cache:   ccache.New(conf),
+		hooks:   make(map[int]func(string)),
+		written: make(map[string]bool),
+		quit:    make(chan struct{}),
+	}
+	if opts.FindPerms != nil && opts.RefreshInterval > 0 {
+		go c.refreshLoop()
+	}
+	return c
+}
+
+// InitRoleCache replaces the cache used by the package functions like ReadPerms, it should be called on start up,
+// the old cache is closed and its roles are discarded, the callers in flight using it read nothing
+func InitRoleCache(opts RoleCacheOptions) *RoleCache {
+	c := NewRoleCache(opts)
+	roleCacheMutex.Lock()
+	old := roleCache
+	roleCache = c
+	roleCacheMutex.Unlock()
+	old.Close()
+	return c
+}
+
+func defaultRoleCache() *RoleCache {
+	roleCacheMutex.RLock()
+	defer roleCacheMutex.RUnlock()
+	return roleCache
+}
+
+// WritePerms save cache, it returns ErrRoleCycle if the role makes an inheritance cycle
 func WritePerms(r *Role) error {
-	permsCache.Set(r.Name, r, DefaultTTL)
-	return nil
+	return defaultRoleCache().Write(r)
 }
 
-// ReadPerms only return data in cache
+// ReadPerms only return data in cache, the perms are the effective perms including the inherited ones.
+// RoleAdmin and RoleDeveloper are always readable: if they are not written, the perms of their templates
+// in BuiltInRoles are returned instead of ErrEmptyPerms, write them to override the templates.
+// Other roles not found return ErrEmptyPerms
 func ReadPerms(roleName string) ([]*Permission, error) {
-	item := permsCache.Get(roleName)
-	if item == nil || item.Value() == nil {
+	return defaultRoleCache().Read(roleName)
+}
+
+// WriteRoles save cache, the roles with invalid perms are skipped, and the roles in an inheritance cycle
+// can not be read by ReadPerms, use ValidateRoles to check them before persisting
+func WriteRoles(roles []*Role) {
+	defaultRoleCache().WriteAll(roles)
+}
+
+// InvalidateRoles removes the roles from cache, it should be called on role change events
+func InvalidateRoles(roleNames ...string) {
+	defaultRoleCache().Invalidate(roleNames...)
+}
+
+// Write saves the role, it returns an error if the perms are invalid, see Role.Validate,
+// or ErrRoleCycle if the role makes an inheritance cycle
+func (c *RoleCache) Write(r *Role) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if _, err := resolvePerms(r, withRoles([]*Role{r}, c.role)); err != nil {
+		return err
+	}
+	c.setWritten(r.Name, true)
+	c.set(r.Name, r, c.opts.TTL)
+	c.notify(r.Name)
+	return nil
+}
+
+// WriteAll saves the roles without checking inheritance cycle, the roles with invalid perms are skipped
+func (c *RoleCache) WriteAll(roles []*Role) {
+	for _, r := range roles {
+		if r.Validate() != nil {
+			continue
+		}
+		c.setWritten(r.Name, true)
+		c.set(r.Name, r, c.opts.TTL)
+		c.notify(r.Name)
+	}
+}
+
+// Read returns the effective perms of the role, it reads through by FindPerms on cache miss,
+// the built-in roles not found use the templates of BuiltInRoles, the same as the parent roles
+func (c *RoleCache) Read(roleName string) ([]*Permission, error) {
+	r, err := c.role(roleName)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		r = builtInRole(roleName)
+	}
+	if r == nil {
 		return nil, ErrEmptyPerms
 	}
-	r, ok := item.Value().(*Role)
-	if !ok {
-		return nil, ErrInvalidPerms
+	return resolvePerms(r, c.role)
+}
+
+// Invalidate removes the roles, they are reloaded by FindPerms on the next read
+func (c *RoleCache) Invalidate(roleNames ...string) {
+	for _, name := range roleNames {
+		c.setWritten(name, false)
+		c.delete(name)
+		c.notify(name)
 	}
-	return r.Perms, nil
 }
 
-func WriteRoles(roles []*Role) {
+// InvalidateAll removes all the roles
+func (c *RoleCache) InvalidateAll() {
+	var names []string
+	c.forEach(func(key string, item *ccache.Item) {
+		names = append(names, key)
+	})
+	c.Invalidate(names...)
+}
+
+// OnInvalidate registers a hook called when a role is written, invalidated, or changed by refresh,
+// the perms derived from the role should be dropped in the hook. it returns a function to unregister
+func (c *RoleCache) OnInvalidate(hook func(roleName string)) func() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	id := c.nextID
+	c.nextID++
+	c.hooks[id] = hook
+	return func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		delete(c.hooks, id)
+	}
+}
+
+// Refresh reloads all the roles by FindPerms, the roles no longer exist are removed,
+// except the ones written to cache and not expired, as they may not be persisted yet.
+// The cache is not changed if FindPerms fails
+func (c *RoleCache) Refresh() error {
+	if c.opts.FindPerms == nil || c.isClosed() {
+		return nil
+	}
+	_, err, _ := c.loader.Do("", func() (interface{}, error) {
+		return nil, c.refresh()
+	})
+	return err
+}
+
+// Close stops the background refresh and releases the cache, the roles can not be written or read after it
+func (c *RoleCache) Close() {
+	c.stopRefresh()
+	c.closeMutex.Lock()
+	defer c.closeMutex.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.cache.Stop()
+}
+
+func (c *RoleCache) stopRefresh() {
+	c.quitOnce.Do(func() {
+		close(c.quit)
+	})
+}
+
+func (c *RoleCache) refresh() error {
+	roles, err := c.opts.FindPerms()
+	if err != nil {
+		return err
+	}
+	found := make(map[string]bool, len(roles))
+	var changed []string
 	for _, r := range roles {
-		permsCache.Set(r.Name, r, 24*time.Hour)
+		found[r.Name] = true
+		c.setWritten(r.Name, false)
+		if old := c.get(r.Name); old == nil || !reflect.DeepEqual(old.Value(), r) {
+			changed = append(changed, r.Name)
+		}
+		c.set(r.Name, r, c.opts.TTL)
+	}
+	var deleted []string
+	c.forEach(func(key string, item *ccache.Item) {
+		if _, absent := item.Value().(absentRole); absent || found[key] {
+			return
+		}
+		if c.isWritten(key) && !item.Expired() {
+			return
+		}
+		deleted = append(deleted, key)
+	})
+	for _, name := range deleted {
+		c.setWritten(name, false)
+		c.delete(name)
+	}
+	for _, name := range append(changed, deleted...) {
+		c.notify(name)
+	}
+	return nil
+}
+
+func (c *RoleCache) refreshLoop() {
+	ticker := time.NewTicker(c.opts.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = c.Refresh()
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// role returns nil role if not found
+func (c *RoleCache) role(roleName string) (*Role, error) {
+	item := c.get(roleName)
+	if item != nil && (!item.Expired() || c.opts.FindPerms == nil) {
+		return roleOf(item)
+	}
+	if c.opts.FindPerms == nil {
+		return nil, nil
+	}
+	if err := c.Refresh(); err != nil {
+		if item != nil {
+			return roleOf(item) // serve the stale role until FindPerms recovers
+		}
+		return nil, err
+	}
+	item = c.get(roleName)
+	if item == nil {
+		c.set(roleName, absentRole{}, c.opts.NegativeTTL)
+		return nil, nil
+	}
+	return roleOf(item)
+}
+
+func (c *RoleCache) setWritten(roleName string, written bool) {
+	c.writtenMutex.Lock()
+	defer c.writtenMutex.Unlock()
+	if written {
+		c.written[roleName] = true
+		return
+	}
+	delete(c.written, roleName)
+}
+
+func (c *RoleCache) isWritten(roleName string) bool {
+	c.writtenMutex.Lock()
+	defer c.writtenMutex.Unlock()
+	return c.written[roleName]
+}
+
+func (c *RoleCache) isClosed() bool {
+	c.closeMutex.RLock()
+	defer c.closeMutex.RUnlock()
+	return c.closed
+}
+
+// get returns nil after the cache is closed
+func (c *RoleCache) get(key string) *ccache.Item {
+	c.closeMutex.RLock()
+	defer c.closeMutex.RUnlock()
+	if c.closed {
+		return nil
+	}
+	return c.cache.Get(key)
+}
+
+func (c *RoleCache) set(key string, value interface{}, ttl time.Duration) {
+	c.closeMutex.RLock()
+	defer c.closeMutex.RUnlock()
+	if c.closed {
+		return
+	}
+	c.cache.Set(key, value, ttl)
+}
+
+func (c *RoleCache) delete(key string) {
+	c.closeMutex.RLock()
+	defer c.closeMutex.RUnlock()
+	if c.closed {
+		return
+	}
+	c.cache.Delete(key)
+}
+
+func (c *RoleCache) forEach(f func(key string, item *ccache.Item)) {
+	c.closeMutex.RLock()
+	defer c.closeMutex.RUnlock()
+	if c.closed {
+		return
+	}
+	c.cache.ForEachFunc(func(key string, item *ccache.Item) bool {
+		f(key, item)
+		return true
+	})
+}
+
+func (c *RoleCache) notify(roleName string) {
+	c.mutex.RLock()
+	hooks := make([]func(string), 0, len(c.hooks))
+	for _, h := range c.hooks {
+		hooks = append(hooks, h)
+	}
+	c.mutex.RUnlock()
+	for _, h := range hooks {
+		h(roleName)
+	}
+}
+
+func roleOf(item *ccache.Item) (*Role, error) {
+	switch v := item.Value().(type) {
+	case absentRole:
+		return nil, nil
+	case *Role:
+		return v, nil
+	default:
+		return nil, ErrInvalidPerms
 	}
 }
@@ -1,9 +1,14 @@
 package rbac_test
 
 import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
 	"github.com/go-chassis/cari/rbac"
 	"github.com/stretchr/testify/assert"
-	"testing"
 )
 
 var roles = []*rbac.Role{
@@ -48,3 +53,191 @@ func TestReadPerms(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+type roleStore struct {
+	mutex sync.Mutex
+	roles []*rbac.Role
+	err   error
+	calls int32
+}
+
+func (s *roleStore) find() ([]*rbac.Role, error) {
+	atomic.AddInt32(&s.calls, 1)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.roles, s.err
+}
+
+func (s *roleStore) set(roles []*rbac.Role, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.roles, s.err = roles, err
+}
+
+func TestRoleCache(t *testing.T) {
+	store := &roleStore{roles: roles}
+	c := rbac.NewRoleCache(rbac.RoleCacheOptions{TTL: 50 * time.Millisecond, FindPerms: store.find})
+	defer c.Close()
+	var invalidated []string
+	var mutex sync.Mutex
+	cancel := c.OnInvalidate(func(roleName string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		invalidated = append(invalidated, roleName)
+	})
+	defer cancel()
+
+	t.Run("cache miss, should read through", func(t *testing.T) {
+		perms, err := c.Read("tester")
+		assert.NoError(t, err)
+		assert.Equal(t, "get", perms[0].Verbs[0])
+		_, err = c.Read("admin")
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&store.calls))
+	})
+	t.Run("role not found, should not reload on every read", func(t *testing.T) {
+		calls := atomic.LoadInt32(&store.calls)
+		_, err := c.Read("a")
+		assert.Equal(t, rbac.ErrEmptyPerms, err)
+		_, err = c.Read("a")
+		assert.Equal(t, rbac.ErrEmptyPerms, err)
+		assert.Equal(t, calls+1, atomic.LoadInt32(&store.calls))
+	})
+	t.Run("role expired, should reload the edited role", func(t *testing.T) {
+		mutex.Lock()
+		invalidated = nil
+		mutex.Unlock()
+		store.set([]*rbac.Role{{Name: "tester", Perms: []*rbac.Permission{{
+			Resources: rbac.BuildResourceList("service"),
+			Verbs:     []string{"create"},
+		}}}}, nil)
+		time.Sleep(60 * time.Millisecond)
+		perms, err := c.Read("tester")
+		assert.NoError(t, err)
+		assert.Equal(t, "create", perms[0].Verbs[0])
+		mutex.Lock()
+		assert.ElementsMatch(t, []string{"tester", "admin"}, invalidated, "tester changed and admin deleted")
+		mutex.Unlock()
+		perms, err = c.Read("admin")
+		assert.NoError(t, err)
+		assert.Equal(t, rbac.Wildcard, perms[0].Resources[0].Type, "deleted admin should fall back to the template")
+	})
+	t.Run("invalidated, should reload", func(t *testing.T) {
+		store.set(roles, nil)
+		c.Invalidate("tester")
+		perms, err := c.Read("tester")
+		assert.NoError(t, err)
+		assert.Equal(t, "get", perms[0].Verbs[0])
+	})
+	t.Run("reload failed, should serve the stale role", func(t *testing.T) {
+		_, err := c.Read("tester")
+		assert.NoError(t, err)
+		store.set(nil, errors.New("db is down"))
+		time.Sleep(60 * time.Millisecond)
+		perms, err := c.Read("tester")
+		assert.NoError(t, err)
+		assert.Equal(t, "get", perms[0].Verbs[0])
+	})
+	t.Run("reload failed without stale role, should return err", func(t *testing.T) {
+		store.set(nil, errors.New("db is down"))
+		c.InvalidateAll()
+		_, err := c.Read("tester")
+		assert.EqualError(t, err, "db is down")
+	})
+}
+
+func TestRoleCache_refresh(t *testing.T) {
+	store := &roleStore{roles: roles}
+	c := rbac.NewRoleCache(rbac.RoleCacheOptions{RefreshInterval: 10 * time.Millisecond, FindPerms: store.find})
+	defer c.Close()
+	_, err := c.Read("tester")
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Write(&rbac.Role{Name: "persisting", Perms: roles[0].Perms}))
+
+	store.set(roles[1:], nil)
+	assert.Eventually(t, func() bool {
+		_, err := c.Read("tester")
+		return err == rbac.ErrEmptyPerms
+	}, time.Second, 10*time.Millisecond)
+	_, err = c.Read("persisting")
+	assert.NoError(t, err, "the written role not persisted yet should be kept")
+
+	store.set([]*rbac.Role{{Name: "persisting", Perms: roles[1].Perms}}, nil)
+	assert.Eventually(t, func() bool {
+		perms, err := c.Read("persisting")
+		return err == nil && perms[0].Verbs[0] == "*"
+	}, time.Second, 10*time.Millisecond)
+	store.set(nil, nil)
+	assert.Eventually(t, func() bool {
+		_, err := c.Read("persisting")
+		return err == rbac.ErrEmptyPerms
+	}, time.Second, 10*time.Millisecond, "the persisted role should be removed once deleted")
+}
+
+func TestInitRoleCache(t *testing.T) {
+	defer rbac.InitRoleCache(rbac.RoleCacheOptions{})
+	rbac.InitRoleCache(rbac.RoleCacheOptions{FindPerms: func() ([]*rbac.Role, error) {
+		return roles, nil
+	}})
+	perms, err := rbac.ReadPerms("admin")
+	assert.NoError(t, err)
+	assert.Equal(t, "*", perms[0].Verbs[0])
+	rbac.InvalidateRoles("admin")
+	_, err = rbac.ReadPerms("admin")
+	assert.NoError(t, err)
+
+	old := rbac.InitRoleCache(rbac.RoleCacheOptions{})
+	rbac.InitRoleCache(rbac.RoleCacheOptions{})
+	old.WriteAll(roles)
+	_, err = old.Read("tester")
+	assert.Equal(t, rbac.ErrEmptyPerms, err, "the old cache should be closed")
+}
+
+func TestRoleCache_Close(t *testing.T) {
+	store := &roleStore{roles: roles}
+	c := rbac.NewRoleCache(rbac.RoleCacheOptions{RefreshInterval: 10 * time.Millisecond, FindPerms: store.find})
+	_, err := c.Read("tester")
+	assert.NoError(t, err)
+
+	c.Close()
+	c.Close()
+	calls := atomic.LoadInt32(&store.calls)
+	assert.NotPanics(t, func() {
+		assert.NoError(t, c.Write(&rbac.Role{Name: "tester"}))
+		c.WriteAll(roles)
+		_, err = c.Read("tester")
+		assert.Equal(t, rbac.ErrEmptyPerms, err)
+		c.Invalidate("tester")
+		c.InvalidateAll()
+		assert.NoError(t, c.Refresh())
+	})
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, calls, atomic.LoadInt32(&store.calls), "should not load after closed")
+}
+
+func TestInitRoleCache_inFlight(t *testing.T) {
+	defer rbac.InitRoleCache(rbac.RoleCacheOptions{})
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				rbac.WriteRoles(roles)
+				_, _ = rbac.ReadPerms("tester")
+			}
+		}
+	}()
+	assert.NotPanics(t, func() {
+		for i := 0; i < 20; i++ {
+			rbac.InitRoleCache(rbac.RoleCacheOptions{})
+		}
+	})
+	close(done)
+	wg.Wait()
+}
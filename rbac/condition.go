@@ -0,0 +1,275 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// operators of a Condition
+const (
+	OperatorIn     = "in"
+	OperatorNotIn  = "notIn"
+	OperatorPrefix = "prefix"
+	OperatorRegex  = "regex"
+)
+
+const timeOfDayLayout = "15:04"
+
+// regexCache caches the compiled Condition regex by the expression
+var regexCache sync.Map
+
+// timeWindowCache caches the parsed TimeWindow by the start, end and location,
+// as loading the location reads the zoneinfo from disk
+var timeWindowCache sync.Map
+
+type timeWindowKey struct {
+	start, end, location string
+}
+
+type parsedTimeWindow struct {
+	startMinute int
+	endMinute   int
+	location    *time.Location
+}
+
+// Condition is an expression on the value of a resource label.
+// a label absent in the request only meets the OperatorNotIn condition
+type Condition struct {
+	Key      string   `json:"key,omitempty"`
+	Operator string   `json:"operator,omitempty"`
+	Values   []string `json:"values,omitempty"`
+}
+
+// Validate checks the operator and the regex
+func (c *Condition) Validate() error {
+	if c.Key == "" {
+		return fmt.Errorf("condition key is empty")
+	}
+	switch c.Operator {
+	case OperatorIn, OperatorNotIn, OperatorPrefix:
+		return nil
+	case OperatorRegex:
+		for _, v := range c.Values {
+			if _, err := compileRegex(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown condition operator: %s", c.Operator)
+	}
+}
+
+// Match returns true if the condition is met by the labels
+func (c *Condition) Match(labels map[string]string) (bool, error) {
+	v, ok := labels[c.Key]
+	switch c.Operator {
+	case OperatorIn:
+		return ok && contains(c.Values, v), nil
+	case OperatorNotIn:
+		return !ok || !contains(c.Values, v), nil
+	case OperatorPrefix:
+		if !ok {
+			return false, nil
+		}
+		for _, prefix := range c.Values {
+			if strings.HasPrefix(v, prefix) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case OperatorRegex:
+		if !ok {
+			return false, nil
+		}
+		for _, expr := range c.Values {
+			re, err := compileRegex(expr)
+			if err != nil {
+				return false, err
+			}
+			if re.MatchString(v) {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown condition operator: %s", c.Operator)
+	}
+}
+
+// TimeWindow is a period of the day, like 09:00 to 18:00 on weekdays.
+// the window crosses midnight if End is before Start, e.g. 22:00 to 06:00
+type TimeWindow struct {
+	// Start and End are in the format of "15:04", End is exclusive
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+	// Weekdays like "Monday", the window applies to all days if empty
+	Weekdays []string `json:"weekdays,omitempty"`
+	// Location is the IANA time zone name like "Asia/Shanghai", UTC if empty
+	Location string `json:"location,omitempty"`
+}
+
+// Validate checks the time format and the location
+func (w *TimeWindow) Validate() error {
+	for _, d := range w.Weekdays {
+		if _, ok := parseWeekday(d); !ok {
+			return fmt.Errorf("invalid time window weekday: %s", d)
+		}
+	}
+	_, err := w.parse()
+	return err
+}
+
+// Contains returns true if t is within the window
+func (w *TimeWindow) Contains(t time.Time) (bool, error) {
+	parsed, err := w.parse()
+	if err != nil {
+		return false, err
+	}
+	t = t.In(parsed.location)
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	var in bool
+	if parsed.startMinute <= parsed.endMinute {
+		in = minute >= parsed.startMinute && minute < parsed.endMinute
+	} else {
+		in = minute >= parsed.startMinute || minute < parsed.endMinute
+		if minute < parsed.endMinute {
+			// the window started yesterday
+			day = (day + 6) % 7
+		}
+	}
+	if !in || len(w.Weekdays) == 0 {
+		return in, nil
+	}
+	for _, d := range w.Weekdays {
+		if wd, ok := parseWeekday(d); ok && wd == day {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (w *TimeWindow) parse() (*parsedTimeWindow, error) {
+	key := timeWindowKey{start: w.Start, end: w.End, location: w.Location}
+	if parsed, ok := timeWindowCache.Load(key); ok {
+		return parsed.(*parsedTimeWindow), nil
+	}
+	start, err := time.Parse(timeOfDayLayout, w.Start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time window start: %s", w.Start)
+	}
+	end, err := time.Parse(timeOfDayLayout, w.End)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time window end: %s", w.End)
+	}
+	loc, err := time.LoadLocation(w.Location)
+	if err != nil {
+		return nil, err
+	}
+	parsed := &parsedTimeWindow{
+		startMinute: start.Hour()*60 + start.Minute(),
+		endMinute:   end.Hour()*60 + end.Minute(),
+		location:    loc,
+	}
+	timeWindowCache.Store(key, parsed)
+	return parsed, nil
+}
+
+// Validate checks the effect, conditions and time windows of the permission
+func (p *Permission) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.Effect != "" && p.Effect != EffectAllow && p.Effect != EffectDeny {
+		return fmt.Errorf("unknown permission effect: %s", p.Effect)
+	}
+	for _, c := range p.Conditions {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, w := range p.TimeWindows {
+		if err := w.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applies returns true if all the conditions and any of the time windows are met,
+// a deny permission which can not be evaluated always applies, an allow one never applies
+func (p *Permission) applies(labels map[string]string, now time.Time) bool {
+	for _, c := range p.Conditions {
+		ok, err := c.Match(labels)
+		if err != nil {
+			return p.IsDeny()
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(p.TimeWindows) == 0 {
+		return true
+	}
+	for _, w := range p.TimeWindows {
+		ok, err := w.Contains(now)
+		if err != nil {
+			return p.IsDeny()
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func compileRegex(expr string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(expr, re)
+	return re, nil
+}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(s, d.String()) || strings.EqualFold(s, d.String()[:3]) {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/cari/rbac"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCondition_Match(t *testing.T) {
+	labels := map[string]string{"environment": "production", "appId": "order-v2"}
+	cases := []struct {
+		name      string
+		condition rbac.Condition
+		expected  bool
+	}{
+		{"in", rbac.Condition{Key: "environment", Operator: rbac.OperatorIn, Values: []string{"testing", "production"}}, true},
+		{"in, absent", rbac.Condition{Key: "region", Operator: rbac.OperatorIn, Values: []string{"cn"}}, false},
+		{"not in", rbac.Condition{Key: "environment", Operator: rbac.OperatorNotIn, Values: []string{"production"}}, false},
+		{"not in, absent", rbac.Condition{Key: "region", Operator: rbac.OperatorNotIn, Values: []string{"cn"}}, true},
+		{"prefix", rbac.Condition{Key: "appId", Operator: rbac.OperatorPrefix, Values: []string{"pay", "order"}}, true},
+		{"regex", rbac.Condition{Key: "appId", Operator: rbac.OperatorRegex, Values: []string{"^order-v[0-9]+$"}}, true},
+		{"regex, not matched", rbac.Condition{Key: "appId", Operator: rbac.OperatorRegex, Values: []string{"^pay"}}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.NoError(t, c.condition.Validate())
+			ok, err := c.condition.Match(labels)
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, ok)
+		})
+	}
+
+	t.Run("invalid, should return err", func(t *testing.T) {
+		c := rbac.Condition{Key: "appId", Operator: rbac.OperatorRegex, Values: []string{"("}}
+		assert.Error(t, c.Validate())
+		_, err := c.Match(labels)
+		assert.Error(t, err)
+		c = rbac.Condition{Key: "appId", Operator: "like"}
+		assert.Error(t, c.Validate())
+	})
+}
+
+func TestTimeWindow_Contains(t *testing.T) {
+	// 2022-01-03 is Monday
+	monday := func(clock string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", "2022-01-03 "+clock)
+		assert.NoError(t, err)
+		return tm
+	}
+	t.Run("office hours", func(t *testing.T) {
+		w := &rbac.TimeWindow{Start: "09:00", End: "18:00", Weekdays: []string{"Mon", "Tuesday"}}
+		assert.NoError(t, w.Validate())
+		for clock, expected := range map[string]bool{"08:59": false, "09:00": true, "17:59": true, "18:00": false} {
+			ok, err := w.Contains(monday(clock))
+			assert.NoError(t, err)
+			assert.Equal(t, expected, ok, clock)
+		}
+		ok, _ := w.Contains(monday("10:00").AddDate(0, 0, 2))
+		assert.False(t, ok, "wednesday")
+	})
+	t.Run("cross midnight, should belong to the start day", func(t *testing.T) {
+		w := &rbac.TimeWindow{Start: "22:00", End: "06:00", Weekdays: []string{"Sunday"}}
+		ok, _ := w.Contains(monday("05:00"))
+		assert.True(t, ok)
+		ok, _ = w.Contains(monday("23:00"))
+		assert.False(t, ok)
+	})
+	t.Run("location", func(t *testing.T) {
+		w := &rbac.TimeWindow{Start: "09:00", End: "18:00", Location: "Asia/Shanghai"}
+		ok, err := w.Contains(monday("02:00"))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+	t.Run("invalid, should return err", func(t *testing.T) {
+		assert.Error(t, (&rbac.TimeWindow{Start: "9am", End: "18:00"}).Validate())
+		assert.Error(t, (&rbac.TimeWindow{Start: "09:00", End: "18:00", Weekdays: []string{"Funday"}}).Validate())
+		assert.Error(t, (&rbac.TimeWindow{Start: "09:00", End: "18:00", Location: "Nowhere"}).Validate())
+	})
+}
+
+func TestPermission_json(t *testing.T) {
+	t.Run("old permission, should be compatible", func(t *testing.T) {
+		b, err := json.Marshal(&rbac.Permission{Resources: rbac.BuildResourceList("service"), Verbs: []string{"get"}})
+		assert.NoError(t, err)
+		assert.Equal(t, `{"resources":[{"type":"service"}],"verbs":["get"]}`, string(b))
+	})
+	t.Run("deny permission, should be decoded", func(t *testing.T) {
+		perm := &rbac.Permission{}
+		err := json.Unmarshal([]byte(`{"resources":[{"type":"service"}],"verbs":["delete"],"effect":"deny",
+"conditions":[{"key":"environment","operator":"in","values":["production"]}]}`), perm)
+		assert.NoError(t, err)
+		assert.True(t, perm.IsDeny())
+		assert.NoError(t, perm.Validate())
+		assert.Equal(t, rbac.OperatorIn, perm.Conditions[0].Operator)
+	})
+	t.Run("unknown effect, should be invalid", func(t *testing.T) {
+		assert.Error(t, (&rbac.Permission{Effect: "maybe"}).Validate())
+	})
+}
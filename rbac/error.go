@@ -49,6 +49,7 @@ const (
 	ErrTokenExpired             int32 = 401205
 	ErrTokenOwnedAccountDeleted int32 = 401206
 	ErrOldPwdWrong              int32 = 401207 // when change password
+	ErrPwdExpired               int32 = 401208 // when login
 
 	ErrAccountBlocked              int32 = 403201
 	ErrForbidOperateBuildInAccount int32 = 403202
@@ -81,6 +82,7 @@ var errorsMap = map[int32]string{
 	ErrTokenExpired:             "Token is expired",
 	ErrTokenOwnedAccountDeleted: "The account that owns the token is deleted",
 	ErrOldPwdWrong:              "Password is wrong",
+	ErrPwdExpired:               "Password is expired",
 
 	ErrAccountConflict: "account name is duplicated",
 	ErrRoleConflict:    "role name is duplicated",
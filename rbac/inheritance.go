@@ -0,0 +1,146 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrRoleCycle = errors.New("role inheritance has a cycle")
+
+// developerResources are the resources of service center and kie which a developer can operate
+var developerResources = []string{ResourceService, ResourceInstance, ResourceSchema, ResourceGovernance, ResourceConfig}
+
+// BuiltInRoles returns the templates of RoleAdmin and RoleDeveloper, they are used if the roles are not
+// written to cache, both for reading them and for the custom roles extending them by Inherits
+func BuiltInRoles() []*Role {
+	return []*Role{builtInRole(RoleAdmin), builtInRole(RoleDeveloper)}
+}
+
+func builtInRole(name string) *Role {
+	switch name {
+	case RoleAdmin:
+		return &Role{Name: RoleAdmin, Perms: []*Permission{{
+			Resources: BuildResourceList(Wildcard),
+			Verbs:     []string{Wildcard},
+		}}}
+	case RoleDeveloper:
+		return &Role{Name: RoleDeveloper, Perms: []*Permission{{
+			Resources: BuildResourceList(developerResources...),
+			Verbs:     []string{Wildcard},
+		}}}
+	default:
+		return nil
+	}
+}
+
+// roleFinder returns nil role if not found
+type roleFinder func(name string) (*Role, error)
+
+// ResolvePerms returns the effective perms of the role from the roles,
+// which are the perms of itself and then the perms of the parents in order of Inherits.
+// a role not in roles is looked up in cache, then in BuiltInRoles, a parent is ignored if not found
+func ResolvePerms(roles []*Role, name string) ([]*Permission, error) {
+	find := withRoles(roles, defaultRoleCache().role)
+	r, err := find(name)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		r = builtInRole(name)
+	}
+	if r == nil {
+		return nil, ErrEmptyPerms
+	}
+	return resolvePerms(r, find)
+}
+
+// ValidateRoles checks the perms of the roles are valid and the roles have no inheritance cycle,
+// the parents not in roles are looked up in cache
+func ValidateRoles(roles []*Role) error {
+	find := withRoles(roles, defaultRoleCache().role)
+	for _, r := range roles {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+		if _, err := resolvePerms(r, find); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func withRoles(roles []*Role, fallback roleFinder) roleFinder {
+	m := make(map[string]*Role, len(roles))
+	for _, r := range roles {
+		m[r.Name] = r
+	}
+	return func(name string) (*Role, error) {
+		if r, ok := m[name]; ok {
+			return r, nil
+		}
+		return fallback(name)
+	}
+}
+
+func resolvePerms(r *Role, find roleFinder) ([]*Permission, error) {
+	if len(r.Inherits) == 0 {
+		return r.Perms, nil
+	}
+	var perms []*Permission
+	err := walkRole(r, find, nil, make(map[string]bool), func(r *Role) {
+		perms = append(perms, r.Perms...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return perms, nil
+}
+
+// walkRole visits the role and its ancestors in depth first order, each role is visited once
+func walkRole(r *Role, find roleFinder, path []string, visited map[string]bool, visit func(r *Role)) error {
+	for i, name := range path {
+		if name == r.Name {
+			return fmt.Errorf("%w: %s", ErrRoleCycle, strings.Join(append(path[i:], r.Name), " -> "))
+		}
+	}
+	if visited[r.Name] {
+		return nil
+	}
+	visited[r.Name] = true
+	visit(r)
+	path = append(path, r.Name)
+	for _, name := range r.Inherits {
+		parent, err := find(name)
+		if err != nil {
+			return err
+		}
+		if parent == nil {
+			parent = builtInRole(name)
+		}
+		if parent == nil {
+			continue
+		}
+		if err := walkRole(parent, find, path, visited, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
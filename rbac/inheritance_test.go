@@ -0,0 +1,136 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-chassis/cari/rbac"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadPerms_inherits(t *testing.T) {
+	viewer := &rbac.Role{Name: "inherit-viewer", Perms: []*rbac.Permission{{
+		Resources: rbac.BuildResourceList("account"),
+		Verbs:     []string{"get"},
+	}}}
+	ops := &rbac.Role{Name: "inherit-ops", Inherits: []string{rbac.RoleDeveloper, "inherit-viewer", "unknown"},
+		Perms: []*rbac.Permission{{
+			Resources: rbac.BuildResourceList("service"),
+			Verbs:     []string{"delete"},
+			Effect:    rbac.EffectDeny,
+		}}}
+	rbac.WriteRoles([]*rbac.Role{viewer, ops})
+
+	t.Run("given ops role, should read perms of itself and parents", func(t *testing.T) {
+		perms, err := rbac.ReadPerms("inherit-ops")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(perms))
+		assert.True(t, perms[0].IsDeny())
+		assert.Equal(t, "get", perms[2].Verbs[0])
+
+		assert.True(t, rbac.Evaluate([]string{"inherit-ops"}, "instance", "create", nil).Allowed)
+		assert.True(t, rbac.Evaluate([]string{"inherit-ops"}, "account", "get", nil).Allowed)
+		assert.False(t, rbac.Evaluate([]string{"inherit-ops"}, "account", "delete", nil).Allowed)
+		assert.False(t, rbac.Evaluate([]string{"inherit-ops"}, "service", "delete", nil).Allowed)
+	})
+	t.Run("parent changed, should read new perms", func(t *testing.T) {
+		assert.NoError(t, rbac.WritePerms(&rbac.Role{Name: "inherit-viewer", Perms: []*rbac.Permission{{
+			Resources: rbac.BuildResourceList("account", "role"),
+			Verbs:     []string{"get"},
+		}}}))
+		assert.True(t, rbac.Evaluate([]string{"inherit-ops"}, "role", "get", nil).Allowed)
+	})
+	t.Run("diamond inheritance, should include the perms once", func(t *testing.T) {
+		rbac.WriteRoles([]*rbac.Role{
+			{Name: "inherit-a", Inherits: []string{"inherit-b", "inherit-c"}},
+			{Name: "inherit-b", Inherits: []string{"inherit-viewer"}},
+			{Name: "inherit-c", Inherits: []string{"inherit-viewer"}},
+		})
+		perms, err := rbac.ReadPerms("inherit-a")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(perms))
+	})
+	t.Run("write a role making cycle, should return err", func(t *testing.T) {
+		err := rbac.WritePerms(&rbac.Role{Name: "inherit-viewer", Inherits: []string{"inherit-ops"}})
+		assert.True(t, errors.Is(err, rbac.ErrRoleCycle))
+		assert.Contains(t, err.Error(), "inherit-viewer -> inherit-ops -> inherit-viewer")
+		_, err = rbac.ReadPerms("inherit-ops")
+		assert.NoError(t, err)
+	})
+	t.Run("roles in cycle, should not be read", func(t *testing.T) {
+		rbac.WriteRoles([]*rbac.Role{
+			{Name: "inherit-x", Inherits: []string{"inherit-y"}},
+			{Name: "inherit-y", Inherits: []string{"inherit-x"}},
+		})
+		_, err := rbac.ReadPerms("inherit-x")
+		assert.True(t, errors.Is(err, rbac.ErrRoleCycle))
+	})
+}
+
+func TestValidateRoles(t *testing.T) {
+	assert.NoError(t, rbac.ValidateRoles(rbac.BuiltInRoles()))
+	assert.NoError(t, rbac.ValidateRoles([]*rbac.Role{
+		{Name: "validate-ops", Inherits: []string{rbac.RoleAdmin}},
+	}))
+	err := rbac.ValidateRoles([]*rbac.Role{
+		{Name: "validate-a", Inherits: []string{"validate-b"}},
+		{Name: "validate-b", Inherits: []string{"validate-a"}},
+	})
+	assert.True(t, errors.Is(err, rbac.ErrRoleCycle))
+
+	perms, err := rbac.ResolvePerms([]*rbac.Role{
+		{Name: "validate-ops", Inherits: []string{rbac.RoleAdmin}},
+	}, "validate-ops")
+	assert.NoError(t, err)
+	assert.Equal(t, rbac.Wildcard, perms[0].Verbs[0])
+}
+
+func TestReadPerms_builtInRoles(t *testing.T) {
+	defer rbac.InitRoleCache(rbac.RoleCacheOptions{})
+	rbac.InitRoleCache(rbac.RoleCacheOptions{})
+	rbac.WriteRoles([]*rbac.Role{{Name: "builtin-ops", Inherits: []string{rbac.RoleAdmin}}})
+
+	t.Run("built-in role not in cache, should use the template", func(t *testing.T) {
+		perms, err := rbac.ReadPerms(rbac.RoleAdmin)
+		assert.NoError(t, err)
+		assert.Equal(t, rbac.Wildcard, perms[0].Verbs[0])
+		assert.True(t, rbac.Evaluate([]string{rbac.RoleAdmin}, "account", "delete", nil).Allowed)
+		assert.True(t, rbac.Evaluate([]string{"builtin-ops"}, "account", "delete", nil).Allowed)
+		for _, resource := range []string{rbac.ResourceService, rbac.ResourceInstance, rbac.ResourceSchema,
+			rbac.ResourceGovernance, rbac.ResourceConfig} {
+			assert.True(t, rbac.Evaluate([]string{rbac.RoleDeveloper}, resource, "create", nil).Allowed)
+		}
+		assert.False(t, rbac.Evaluate([]string{rbac.RoleDeveloper}, "account", "delete", nil).Allowed)
+	})
+	t.Run("built-in role in cache, should use the cached one for itself and children", func(t *testing.T) {
+		assert.NoError(t, rbac.WritePerms(&rbac.Role{Name: rbac.RoleAdmin, Perms: []*rbac.Permission{{
+			Resources: rbac.BuildResourceList("service"),
+			Verbs:     []string{"get"},
+		}}}))
+		assert.False(t, rbac.Evaluate([]string{rbac.RoleAdmin}, "account", "delete", nil).Allowed)
+		assert.False(t, rbac.Evaluate([]string{"builtin-ops"}, "account", "delete", nil).Allowed)
+		assert.True(t, rbac.Evaluate([]string{"builtin-ops"}, "service", "get", nil).Allowed)
+	})
+	t.Run("resolve built-in role, should use the template", func(t *testing.T) {
+		perms, err := rbac.ResolvePerms(nil, rbac.RoleDeveloper)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, perms)
+	})
+}
@@ -0,0 +1,120 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// PasswordPolicy is the rules of the new password, the zero value of a rule disables it
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	// MinCharClasses the min count of the classes in upper case, lower case, digit and special characters
+	MinCharClasses int
+	// BannedWords the password must not contain any of them, case insensitive
+	BannedWords []string
+	// HistorySize the password must not be the same as the last HistorySize passwords
+	HistorySize int
+	// MaxAge the password must be changed after MaxAge
+	MaxAge time.Duration
+}
+
+// DefaultPasswordPolicy is used by CheckPassword
+var DefaultPasswordPolicy = &PasswordPolicy{
+	MinLength:      8,
+	MaxLength:      64,
+	MinCharClasses: 3,
+	BannedWords:    []string{"password", "admin", "servicecomb"},
+}
+
+// CheckPassword checks the new password of the account by DefaultPasswordPolicy
+func CheckPassword(a *Account, history ...string) error {
+	return DefaultPasswordPolicy.Check(a, history...)
+}
+
+// Check checks the new password of the account, history is the hashes of the recent passwords
+// in order from the newest, see PasswordHasher.
+// It returns an ErrNewPwdBad error with all the broken rules in detail
+func (p *PasswordPolicy) Check(a *Account, history ...string) error {
+	reasons := make([]string, 0)
+	if err := a.Check(); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	length := len([]rune(a.Password))
+	if p.MinLength > 0 && length < p.MinLength {
+		reasons = append(reasons, fmt.Sprintf("password MUST be at least %d characters", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		reasons = append(reasons, fmt.Sprintf("password MUST be at most %d characters", p.MaxLength))
+	}
+	if p.MinCharClasses > 0 && charClasses(a.Password) < p.MinCharClasses {
+		reasons = append(reasons, fmt.Sprintf("password MUST contain at least %d of upper case, lower case, digit and special characters", p.MinCharClasses))
+	}
+	lower := strings.ToLower(a.Password)
+	for _, word := range p.BannedWords {
+		if len(word) > 0 && strings.Contains(lower, strings.ToLower(word)) {
+			reasons = append(reasons, fmt.Sprintf("password MUST NOT contain %q", word))
+		}
+	}
+	if p.HistorySize > 0 {
+		if len(history) > p.HistorySize {
+			history = history[:p.HistorySize]
+		}
+		for _, hash := range history {
+			if ok, _ := VerifyPassword(a.Password, hash); ok {
+				reasons = append(reasons, fmt.Sprintf("password MUST NOT be the same as the last %d passwords", p.HistorySize))
+				break
+			}
+		}
+	}
+
+	if len(reasons) > 0 {
+		return NewError(ErrNewPwdBad, strings.Join(reasons, "; "))
+	}
+	return nil
+}
+
+// CheckAge returns an ErrPwdExpired error if the password changed at changedAt is older than MaxAge
+func (p *PasswordPolicy) CheckAge(changedAt, now time.Time) error {
+	if p.MaxAge <= 0 || now.Sub(changedAt) <= p.MaxAge {
+		return nil
+	}
+	return NewError(ErrPwdExpired, fmt.Sprintf("password is not changed in %s", p.MaxAge))
+}
+
+func charClasses(pwd string) int {
+	var upper, lower, digit, special int
+	for _, r := range pwd {
+		switch {
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			special = 1
+		}
+	}
+	return upper + lower + digit + special
+}
@@ -0,0 +1,338 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	HashArgon2id = "argon2id"
+	HashScrypt   = "scrypt"
+	HashBcrypt   = "bcrypt"
+)
+
+// the bounds of the parameters parsed from a hash, a hash out of them is invalid rather than
+// exhausting the memory or cpu on verifying
+const (
+	maxArgon2Memory     = 1 << 20 // KiB, 1 GiB
+	maxArgon2Iterations = 64
+	maxScryptLogN       = 20
+	maxScryptR          = 64
+	maxScryptP          = 16
+)
+
+// ErrInvalidHash is returned when the hash is not in a supported format
+var ErrInvalidHash = errors.New("invalid password hash")
+
+// Argon2Params are the argon2id parameters, Memory is in KiB
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  int
+	KeyLength   int
+}
+
+// ScryptParams are the scrypt parameters, N is 2^LogN
+type ScryptParams struct {
+	LogN       int
+	R          int
+	P          int
+	SaltLength int
+	KeyLength  int
+}
+
+var (
+	defaultArgon2Params = Argon2Params{Memory: 19 * 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	defaultScryptParams = ScryptParams{LogN: 15, R: 8, P: 1, SaltLength: 16, KeyLength: 32}
+)
+
+// PasswordHasher hashes the passwords in the PHC string format, e.g.
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>, $scrypt$ln=15,r=8,p=1$<salt>$<hash>,
+// and bcrypt in its own format $2a$10$<salt and hash>
+// The zero value of a parameter uses the default, the defaults are recommended by OWASP
+type PasswordHasher struct {
+	Algorithm  string       // default argon2id
+	Argon2     Argon2Params // default m=19456,t=2,p=1, 16 bytes salt and 32 bytes key
+	Scrypt     ScryptParams // default ln=15,r=8,p=1, 16 bytes salt and 32 bytes key
+	BcryptCost int          // default 10
+}
+
+// DefaultPasswordHasher hashes by argon2id with the default parameters
+var DefaultPasswordHasher = &PasswordHasher{Algorithm: HashArgon2id}
+
+// HashPassword hashes the password by DefaultPasswordHasher
+func HashPassword(pwd string) (string, error) {
+	return DefaultPasswordHasher.Hash(pwd)
+}
+
+// Hash returns the hash of the password, it returns an ErrNewPwdBad error if the password can not be hashed
+func (h *PasswordHasher) Hash(pwd string) (string, error) {
+	switch h.algorithm() {
+	case HashArgon2id:
+		params := h.argon2Params()
+		if !params.isValid() {
+			return "", fmt.Errorf("argon2 parameters m=%d,t=%d,p=%d are out of bounds",
+				params.Memory, params.Iterations, params.Parallelism)
+		}
+		salt, err := newSalt(params.SaltLength)
+		if err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(pwd), salt, params.Iterations, params.Memory, params.Parallelism, uint32(params.KeyLength))
+		return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", HashArgon2id, argon2.Version,
+			params.Memory, params.Iterations, params.Parallelism, encodeBase64(salt), encodeBase64(key)), nil
+	case HashScrypt:
+		params := h.scryptParams()
+		if !params.isValid() {
+			return "", fmt.Errorf("scrypt parameters ln=%d,r=%d,p=%d are out of bounds", params.LogN, params.R, params.P)
+		}
+		salt, err := newSalt(params.SaltLength)
+		if err != nil {
+			return "", err
+		}
+		key, err := scrypt.Key([]byte(pwd), salt, 1<<params.LogN, params.R, params.P, params.KeyLength)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("$%s$ln=%d,r=%d,p=%d$%s$%s", HashScrypt,
+			params.LogN, params.R, params.P, encodeBase64(salt), encodeBase64(key)), nil
+	case HashBcrypt:
+		if len(pwd) > 72 {
+			return "", NewError(ErrNewPwdBad, "password MUST be at most 72 bytes for bcrypt")
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(pwd), h.bcryptCost())
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+	return "", fmt.Errorf("unsupported password hash algorithm %s", h.Algorithm)
+}
+
+// NeedsRehash reports whether the hash is not made by the algorithm and parameters of the hasher
+func (h *PasswordHasher) NeedsRehash(hash string) bool {
+	p, err := parseHash(hash)
+	if err != nil {
+		return true
+	}
+	switch p.algorithm {
+	case HashArgon2id:
+		return h.algorithm() != HashArgon2id || p.argon2 != h.argon2Params()
+	case HashScrypt:
+		return h.algorithm() != HashScrypt || p.scrypt != h.scryptParams()
+	}
+	return h.algorithm() != HashBcrypt || p.bcryptCost != h.bcryptCost()
+}
+
+// VerifyAndRehash verifies the password on login, newHash is not empty if the hash needs to be upgraded
+// to the algorithm and parameters of the hasher, the caller should store it instead of the old one.
+// It returns an ErrUserOrPwdWrong error if the password is wrong
+func (h *PasswordHasher) VerifyAndRehash(pwd, hash string) (newHash string, err error) {
+	ok, err := VerifyPassword(pwd, hash)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", NewError(ErrUserOrPwdWrong, "")
+	}
+	if !h.NeedsRehash(hash) {
+		return "", nil
+	}
+	return h.Hash(pwd)
+}
+
+// VerifyPassword reports whether the password matches the hash of any supported algorithm
+func VerifyPassword(pwd, hash string) (bool, error) {
+	p, err := parseHash(hash)
+	if err != nil {
+		return false, err
+	}
+	var key []byte
+	switch p.algorithm {
+	case HashArgon2id:
+		key = argon2.IDKey([]byte(pwd), p.salt, p.argon2.Iterations, p.argon2.Memory, p.argon2.Parallelism, uint32(len(p.key)))
+	case HashScrypt:
+		key, err = scrypt.Key([]byte(pwd), p.salt, 1<<p.scrypt.LogN, p.scrypt.R, p.scrypt.P, len(p.key))
+		if err != nil {
+			return false, err
+		}
+	default:
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(pwd))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return subtle.ConstantTimeCompare(key, p.key) == 1, nil
+}
+
+type parsedHash struct {
+	algorithm  string
+	argon2     Argon2Params
+	scrypt     ScryptParams
+	bcryptCost int
+	salt       []byte
+	key        []byte
+}
+
+func parseHash(hash string) (*parsedHash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) < 4 || len(parts[0]) != 0 {
+		return nil, ErrInvalidHash
+	}
+	p := &parsedHash{algorithm: parts[1]}
+	var err error
+	switch p.algorithm {
+	case HashArgon2id:
+		var version int
+		if len(parts) != 6 {
+			return nil, ErrInvalidHash
+		}
+		if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+			return nil, ErrInvalidHash
+		}
+		if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.argon2.Memory, &p.argon2.Iterations, &p.argon2.Parallelism); err != nil {
+			return nil, ErrInvalidHash
+		}
+		if !p.argon2.isValid() {
+			return nil, ErrInvalidHash
+		}
+		p.salt, p.key, err = decodeSaltAndKey(parts[4], parts[5])
+		p.argon2.SaltLength, p.argon2.KeyLength = len(p.salt), len(p.key)
+	case HashScrypt:
+		if len(parts) != 5 {
+			return nil, ErrInvalidHash
+		}
+		if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &p.scrypt.LogN, &p.scrypt.R, &p.scrypt.P); err != nil {
+			return nil, ErrInvalidHash
+		}
+		if !p.scrypt.isValid() {
+			return nil, ErrInvalidHash
+		}
+		p.salt, p.key, err = decodeSaltAndKey(parts[3], parts[4])
+		p.scrypt.SaltLength, p.scrypt.KeyLength = len(p.salt), len(p.key)
+	default:
+		p.algorithm = HashBcrypt
+		p.bcryptCost, err = bcrypt.Cost([]byte(hash))
+	}
+	if err != nil {
+		return nil, ErrInvalidHash
+	}
+	return p, nil
+}
+
+// isValid reports whether the parameters are acceptable by argon2 and within the bounds
+func (a Argon2Params) isValid() bool {
+	return a.Parallelism >= 1 && a.Iterations >= 1 && a.Iterations <= maxArgon2Iterations &&
+		a.Memory >= 8*uint32(a.Parallelism) && a.Memory <= maxArgon2Memory
+}
+
+// isValid reports whether the parameters are acceptable by scrypt and within the bounds
+func (s ScryptParams) isValid() bool {
+	return s.LogN >= 1 && s.LogN <= maxScryptLogN && s.R >= 1 && s.R <= maxScryptR && s.P >= 1 && s.P <= maxScryptP
+}
+
+func decodeSaltAndKey(salt, key string) ([]byte, []byte, error) {
+	s, err := base64.RawStdEncoding.DecodeString(salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	k, err := base64.RawStdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(s) == 0 || len(k) == 0 {
+		return nil, nil, ErrInvalidHash
+	}
+	return s, k, nil
+}
+
+func (h *PasswordHasher) algorithm() string {
+	if len(h.Algorithm) == 0 {
+		return HashArgon2id
+	}
+	return h.Algorithm
+}
+
+func (h *PasswordHasher) argon2Params() Argon2Params {
+	params := h.Argon2
+	if params.Memory == 0 {
+		params.Memory = defaultArgon2Params.Memory
+	}
+	if params.Iterations == 0 {
+		params.Iterations = defaultArgon2Params.Iterations
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = defaultArgon2Params.Parallelism
+	}
+	if params.SaltLength <= 0 {
+		params.SaltLength = defaultArgon2Params.SaltLength
+	}
+	if params.KeyLength <= 0 {
+		params.KeyLength = defaultArgon2Params.KeyLength
+	}
+	return params
+}
+
+func (h *PasswordHasher) scryptParams() ScryptParams {
+	params := h.Scrypt
+	if params.LogN <= 0 {
+		params.LogN = defaultScryptParams.LogN
+	}
+	if params.R <= 0 {
+		params.R = defaultScryptParams.R
+	}
+	if params.P <= 0 {
+		params.P = defaultScryptParams.P
+	}
+	if params.SaltLength <= 0 {
+		params.SaltLength = defaultScryptParams.SaltLength
+	}
+	if params.KeyLength <= 0 {
+		params.KeyLength = defaultScryptParams.KeyLength
+	}
+	return params
+}
+
+func (h *PasswordHasher) bcryptCost() int {
+	if h.BcryptCost <= 0 {
+		return bcrypt.DefaultCost
+	}
+	return h.BcryptCost
+}
+
+func newSalt(n int) ([]byte, error) {
+	salt := make([]byte, n)
+	_, err := rand.Read(salt)
+	return salt, err
+}
+
+func encodeBase64(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,178 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/cari/pkg/errsvc"
+	"github.com/go-chassis/cari/rbac"
+)
+
+func TestPasswordPolicy_Check(t *testing.T) {
+	policy := &rbac.PasswordPolicy{
+		MinLength:      8,
+		MaxLength:      16,
+		MinCharClasses: 3,
+		BannedWords:    []string{"Password"},
+		HistorySize:    2,
+	}
+	h1, err := rbac.HashPassword("Old-pwd-1")
+	assert.NoError(t, err)
+	h2, err := rbac.HashPassword("Old-pwd-2")
+	assert.NoError(t, err)
+	h3, err := rbac.HashPassword("Old-pwd-3")
+	assert.NoError(t, err)
+	history := []string{h1, h2, h3}
+
+	tests := []struct {
+		name    string
+		pwd     string
+		reasons []string
+	}{
+		{name: "good password", pwd: "Good-pwd-1"},
+		{name: "too short", pwd: "Ab-1", reasons: []string{"at least 8 characters"}},
+		{name: "too long", pwd: "Abcdefgh-12345678", reasons: []string{"at most 16 characters"}},
+		{name: "too simple", pwd: "abcdefgh1", reasons: []string{"at least 3 of"}},
+		{name: "banned word", pwd: "my-PASSWORD-1", reasons: []string{"MUST NOT contain \"Password\""}},
+		{name: "recent password", pwd: "Old-pwd-2", reasons: []string{"last 2 passwords"}},
+		{name: "password out of history size", pwd: "Old-pwd-3"},
+		{name: "same as name", pwd: "test", reasons: []string{rbac.ErrSameAsName.Error(), "at least 8 characters"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(&rbac.Account{Name: "test", Password: tt.pwd}, history...)
+			if len(tt.reasons) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errsvc.IsErrEqualCode(err, rbac.ErrNewPwdBad))
+			for _, reason := range tt.reasons {
+				assert.Contains(t, err.(*errsvc.Error).Detail, reason)
+			}
+		})
+	}
+	assert.NoError(t, rbac.CheckPassword(&rbac.Account{Name: "test", Password: "Test-a1-b2"}))
+}
+
+func TestPasswordPolicy_CheckAge(t *testing.T) {
+	now := time.Now()
+	policy := &rbac.PasswordPolicy{}
+	assert.NoError(t, policy.CheckAge(now.Add(-365*24*time.Hour), now), "disabled by default")
+	policy.MaxAge = 90 * 24 * time.Hour
+	assert.NoError(t, policy.CheckAge(now.Add(-24*time.Hour), now))
+	err := policy.CheckAge(now.Add(-91*24*time.Hour), now)
+	assert.True(t, errsvc.IsErrEqualCode(err, rbac.ErrPwdExpired))
+}
+
+func TestPasswordHasher(t *testing.T) {
+	for _, alg := range []string{rbac.HashArgon2id, rbac.HashScrypt, rbac.HashBcrypt} {
+		t.Run(alg, func(t *testing.T) {
+			h := &rbac.PasswordHasher{Algorithm: alg, Scrypt: rbac.ScryptParams{LogN: 10}, BcryptCost: 4}
+			hash, err := h.Hash("Test-a1-b2")
+			assert.NoError(t, err)
+			another, err := h.Hash("Test-a1-b2")
+			assert.NoError(t, err)
+			assert.NotEqual(t, hash, another, "salt should be random")
+			assert.False(t, h.NeedsRehash(hash))
+
+			ok, err := rbac.VerifyPassword("Test-a1-b2", hash)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			ok, err = rbac.VerifyPassword("Test-a1-b3", hash)
+			assert.NoError(t, err)
+			assert.False(t, ok)
+		})
+	}
+
+	hash, err := rbac.HashPassword("Test-a1-b2")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^\$argon2id\$v=19\$m=19456,t=2,p=1\$[^$]+\$[^$]+$`, hash)
+
+	_, err = rbac.VerifyPassword("Test-a1-b2", "plain")
+	assert.Equal(t, rbac.ErrInvalidHash, err)
+	_, err = rbac.VerifyPassword("Test-a1-b2", "$argon2id$v=19$m=1,t=1,p=1$!$!")
+	assert.Equal(t, rbac.ErrInvalidHash, err)
+
+	_, err = (&rbac.PasswordHasher{Algorithm: rbac.HashBcrypt}).Hash(string(make([]byte, 73)))
+	assert.True(t, errsvc.IsErrEqualCode(err, rbac.ErrNewPwdBad))
+}
+
+func TestVerifyPassword_invalidParams(t *testing.T) {
+	const saltAndKey = "$c2FsdHNhbHQ$a2V5a2V5a2V5"
+	for _, hash := range []string{
+		"$argon2id$v=19$m=19456,t=0,p=1" + saltAndKey,
+		"$argon2id$v=19$m=19456,t=65,p=1" + saltAndKey,
+		"$argon2id$v=19$m=19456,t=2,p=0" + saltAndKey,
+		"$argon2id$v=19$m=7,t=2,p=1" + saltAndKey,
+		"$argon2id$v=19$m=31,t=2,p=4" + saltAndKey,
+		"$argon2id$v=19$m=4294967295,t=2,p=1" + saltAndKey,
+		"$argon2id$v=19$m=19456,t=2,p=256" + saltAndKey,
+		"$scrypt$ln=0,r=8,p=1" + saltAndKey,
+		"$scrypt$ln=21,r=8,p=1" + saltAndKey,
+		"$scrypt$ln=64,r=8,p=1" + saltAndKey,
+		"$scrypt$ln=-1,r=8,p=1" + saltAndKey,
+		"$scrypt$ln=10,r=0,p=1" + saltAndKey,
+		"$scrypt$ln=10,r=65,p=1" + saltAndKey,
+		"$scrypt$ln=10,r=8,p=0" + saltAndKey,
+		"$scrypt$ln=10,r=8,p=17" + saltAndKey,
+	} {
+		t.Run(hash, func(t *testing.T) {
+			_, err := rbac.VerifyPassword("Test-a1-b2", hash)
+			assert.Equal(t, rbac.ErrInvalidHash, err)
+			assert.True(t, (&rbac.PasswordHasher{}).NeedsRehash(hash))
+		})
+	}
+
+	for _, hash := range []string{
+		"$argon2id$v=19$m=8,t=1,p=1" + saltAndKey,
+		"$scrypt$ln=1,r=1,p=1" + saltAndKey,
+	} {
+		ok, err := rbac.VerifyPassword("Test-a1-b2", hash)
+		assert.NoError(t, err, hash)
+		assert.False(t, ok)
+	}
+
+	_, err := (&rbac.PasswordHasher{Argon2: rbac.Argon2Params{Memory: 2 << 20}}).Hash("Test-a1-b2")
+	assert.Error(t, err, "should not make a hash which can not be verified")
+	_, err = (&rbac.PasswordHasher{Algorithm: rbac.HashScrypt, Scrypt: rbac.ScryptParams{LogN: 21}}).Hash("Test-a1-b2")
+	assert.Error(t, err)
+}
+
+func TestPasswordHasher_VerifyAndRehash(t *testing.T) {
+	old := &rbac.PasswordHasher{Algorithm: rbac.HashBcrypt, BcryptCost: 4}
+	hash, err := old.Hash("Test-a1-b2")
+	assert.NoError(t, err)
+
+	h := &rbac.PasswordHasher{Algorithm: rbac.HashScrypt, Scrypt: rbac.ScryptParams{LogN: 10}}
+	_, err = h.VerifyAndRehash("Test-a1-b3", hash)
+	assert.True(t, errsvc.IsErrEqualCode(err, rbac.ErrUserOrPwdWrong))
+
+	newHash, err := h.VerifyAndRehash("Test-a1-b2", hash)
+	assert.NoError(t, err)
+	assert.Contains(t, newHash, "$scrypt$ln=10,r=8,p=1$")
+	newer, err := h.VerifyAndRehash("Test-a1-b2", newHash)
+	assert.NoError(t, err)
+	assert.Empty(t, newer, "should not rehash the hash of the same parameters")
+
+	h.Scrypt.LogN = 11
+	assert.True(t, h.NeedsRehash(newHash), "should rehash when parameters changed")
+}
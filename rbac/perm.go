@@ -21,10 +21,31 @@ type SelfPermissionResponse struct {
 	Perms []*Permission `json:"perms,omitempty"`
 }
 
+// effect of a Permission
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Permission grants the verbs on the resources, or denies them if the Effect is EffectDeny,
+// a deny permission takes precedence over all the grants of the account roles
 type Permission struct {
 	Resources []*Resource `json:"resources,omitempty"`
 	Verbs     []string    `json:"verbs,omitempty"`
+	// Effect is EffectAllow if empty
+	Effect string `json:"effect,omitempty"`
+	// Conditions on the resource labels, the permission applies only if all of them are met
+	Conditions []*Condition `json:"conditions,omitempty"`
+	// TimeWindows the permission applies only within any of them, or always if empty
+	TimeWindows []*TimeWindow `json:"timeWindows,omitempty"`
+}
+
+// IsDeny returns true if the permission is a deny rule, an unknown effect is a deny rule too,
+// e.g. "Deny", so a mistyped effect never grants anything
+func (p *Permission) IsDeny() bool {
+	return p.Effect != "" && p.Effect != EffectAllow
 }
+
 type Resource struct {
 	Type   string            `json:"type,omitempty"`
 	Labels map[string]string `json:"labels,omitempty"`
@@ -0,0 +1,152 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+)
+
+// Wildcard matches any verb, resource type or label value in a Permission
+const Wildcard = "*"
+
+// Decision is the result of a policy evaluation
+type Decision struct {
+	Allowed bool
+	// Role, Permission and Resource are the rule matched the request,
+	// they are nil if no rule matched, or the deny rule if denied explicitly
+	Role       string
+	Permission *Permission
+	Resource   *Resource
+	// Reason describes why the request is allowed or not
+	Reason string
+}
+
+// Err returns the ErrNoPermission error if the request is not allowed
+func (d *Decision) Err() error {
+	if d.Allowed {
+		return nil
+	}
+	return NewError(ErrNoPermission, d.Reason)
+}
+
+// Allow decides whether the account in ctx can perform the verb on the resource,
+// the perms of the account roles are read by ReadPerms, so the roles other than the built-in ones
+// must be written to cache in advance.
+// labels are the labels of the requested resource, a rule with labels only matches if all of its labels match
+func Allow(ctx context.Context, resourceType, verb string, labels map[string]string) (*Decision, error) {
+	roles, err := RolesFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return Evaluate(roles, resourceType, verb, labels), nil
+}
+
+// Evaluate decides whether the roles can perform the verb on the resource, see Allow.
+// a matched deny permission of any role takes precedence over the grants
+func Evaluate(roles []string, resourceType, verb string, labels map[string]string) *Decision {
+	return evaluate(roles, resourceType, verb, labels, time.Now())
+}
+
+func evaluate(roles []string, resourceType, verb string, labels map[string]string, now time.Time) *Decision {
+	var allowed *Decision
+	for _, role := range roles {
+		perms, err := ReadPerms(role)
+		if err != nil {
+			continue
+		}
+		for _, perm := range perms {
+			if perm == nil || (allowed != nil && !perm.IsDeny()) || !matchVerb(perm.Verbs, verb) {
+				continue
+			}
+			res := matchResources(perm.Resources, resourceType, labels)
+			if res == nil || !perm.applies(labels, now) {
+				continue
+			}
+			if perm.IsDeny() {
+				return &Decision{
+					Role:       role,
+					Permission: perm,
+					Resource:   res,
+					Reason:     fmt.Sprintf("denied by role %s", role),
+				}
+			}
+			allowed = &Decision{
+				Allowed:    true,
+				Role:       role,
+				Permission: perm,
+				Resource:   res,
+				Reason:     fmt.Sprintf("allowed by role %s", role),
+			}
+		}
+	}
+	if allowed != nil {
+		return allowed
+	}
+	return &Decision{
+		Reason: fmt.Sprintf("no permission to %s %s", verb, resourceType),
+	}
+}
+
+func matchVerb(verbs []string, verb string) bool {
+	for _, v := range verbs {
+		if v == Wildcard || v == verb {
+			return true
+		}
+	}
+	return false
+}
+
+func matchResources(resources []*Resource, resourceType string, labels map[string]string) *Resource {
+	for _, res := range resources {
+		if res != nil && match(res.Type, resourceType) && matchLabels(res.Labels, labels) {
+			return res
+		}
+	}
+	return nil
+}
+
+// matchLabels returns true if all the selector labels match,
+// a label absent in the request only matches the Wildcard
+func matchLabels(selector, labels map[string]string) bool {
+	for k, pattern := range selector {
+		v, ok := labels[k]
+		if !ok {
+			if pattern == Wildcard {
+				continue
+			}
+			return false
+		}
+		if !match(pattern, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// match supports the Wildcard and glob pattern like "app-*",
+// an invalid pattern only matches the same string
+func match(pattern, s string) bool {
+	if pattern == Wildcard || pattern == s {
+		return true
+	}
+	ok, err := path.Match(pattern, s)
+	return err == nil && ok
+}
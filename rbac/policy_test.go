@@ -0,0 +1,168 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-chassis/cari/pkg/errsvc"
+	"github.com/go-chassis/cari/rbac"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllow(t *testing.T) {
+	rbac.WriteRoles([]*rbac.Role{
+		{
+			Name: "policy-viewer",
+			Perms: []*rbac.Permission{{
+				Resources: []*rbac.Resource{{Type: "service", Labels: map[string]string{"environment": "*", "appId": "app-*"}}},
+				Verbs:     []string{"get"},
+			}},
+		},
+		{
+			Name: "policy-operator",
+			Perms: []*rbac.Permission{{
+				Resources: []*rbac.Resource{{Type: "*"}},
+				Verbs:     []string{"*"},
+			}},
+		},
+	})
+	viewer := rbac.NewContext(context.TODO(), map[string]interface{}{
+		rbac.ClaimsUser:  "viewer",
+		rbac.ClaimsRoles: []interface{}{"unknown", "policy-viewer"},
+	})
+
+	t.Run("labels matched, should be allowed", func(t *testing.T) {
+		d, err := rbac.Allow(viewer, "service", "get", map[string]string{"appId": "app-order"})
+		assert.NoError(t, err)
+		assert.True(t, d.Allowed)
+		assert.Equal(t, "policy-viewer", d.Role)
+		assert.Equal(t, "service", d.Resource.Type)
+		assert.NoError(t, d.Err())
+	})
+	t.Run("label not matched, should be denied", func(t *testing.T) {
+		d, err := rbac.Allow(viewer, "service", "get", map[string]string{"appId": "default"})
+		assert.NoError(t, err)
+		assert.False(t, d.Allowed)
+		assert.Nil(t, d.Permission)
+		assert.True(t, errsvc.IsErrEqualCode(d.Err(), rbac.ErrNoPermission))
+	})
+	t.Run("label absent, should be denied", func(t *testing.T) {
+		d, err := rbac.Allow(viewer, "service", "get", nil)
+		assert.NoError(t, err)
+		assert.False(t, d.Allowed)
+	})
+	t.Run("verb not matched, should be denied", func(t *testing.T) {
+		d, err := rbac.Allow(viewer, "service", "delete", map[string]string{"appId": "app-order"})
+		assert.NoError(t, err)
+		assert.False(t, d.Allowed)
+	})
+	t.Run("wildcard verb and type, should be allowed", func(t *testing.T) {
+		d := rbac.Evaluate([]string{"policy-viewer", "policy-operator"}, "account", "delete", nil)
+		assert.True(t, d.Allowed)
+		assert.Equal(t, "policy-operator", d.Role)
+	})
+	t.Run("no account in context, should return err", func(t *testing.T) {
+		_, err := rbac.Allow(context.TODO(), "service", "get", nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestAllow_deny(t *testing.T) {
+	rbac.WriteRoles([]*rbac.Role{
+		{
+			Name: "policy-developer",
+			Perms: []*rbac.Permission{
+				{
+					Resources: []*rbac.Resource{{Type: "service"}},
+					Verbs:     []string{"*"},
+				},
+				{
+					Resources: []*rbac.Resource{{Type: "service"}},
+					Verbs:     []string{"delete"},
+					Effect:    rbac.EffectDeny,
+					Conditions: []*rbac.Condition{
+						{Key: "environment", Operator: rbac.OperatorIn, Values: []string{"production"}},
+					},
+				},
+			},
+		},
+		{
+			Name: "policy-night",
+			Perms: []*rbac.Permission{{
+				Resources:   []*rbac.Resource{{Type: "*"}},
+				Verbs:       []string{"*"},
+				Effect:      rbac.EffectDeny,
+				TimeWindows: []*rbac.TimeWindow{{Start: "00:00", End: "00:00"}},
+			}},
+		},
+	})
+	developer := []string{"policy-developer"}
+
+	t.Run("delete in testing, should be allowed", func(t *testing.T) {
+		d := rbac.Evaluate(developer, "service", "delete", map[string]string{"environment": "testing"})
+		assert.True(t, d.Allowed)
+	})
+	t.Run("delete in production, should be denied", func(t *testing.T) {
+		d := rbac.Evaluate(developer, "service", "delete", map[string]string{"environment": "production"})
+		assert.False(t, d.Allowed)
+		assert.True(t, d.Permission.IsDeny())
+		assert.True(t, errsvc.IsErrEqualCode(d.Err(), rbac.ErrNoPermission))
+	})
+	t.Run("update in production, should be allowed", func(t *testing.T) {
+		d := rbac.Evaluate(developer, "service", "update", map[string]string{"environment": "production"})
+		assert.True(t, d.Allowed)
+	})
+	t.Run("deny of another role, should take precedence", func(t *testing.T) {
+		d := rbac.Evaluate([]string{"policy-developer", "policy-night"}, "service", "get", nil)
+		assert.True(t, d.Allowed, "window 00:00-00:00 is empty")
+		d = rbac.Evaluate([]string{"admin", "policy-developer"}, "service", "delete",
+			map[string]string{"environment": "production"})
+		assert.False(t, d.Allowed)
+		assert.Equal(t, "policy-developer", d.Role)
+	})
+}
+
+func TestAllow_unknownEffect(t *testing.T) {
+	mistyped := &rbac.Role{
+		Name: "policy-mistyped",
+		Perms: []*rbac.Permission{{
+			Resources: []*rbac.Resource{{Type: "service"}},
+			Verbs:     []string{"delete"},
+			Effect:    "Deny",
+		}},
+	}
+	t.Run("write, should be rejected", func(t *testing.T) {
+		assert.Error(t, rbac.WritePerms(mistyped))
+		assert.Error(t, rbac.ValidateRoles([]*rbac.Role{mistyped}))
+		rbac.WriteRoles([]*rbac.Role{mistyped})
+		_, err := rbac.ReadPerms(mistyped.Name)
+		assert.Error(t, err)
+	})
+	t.Run("loaded from storage, should be a deny rule", func(t *testing.T) {
+		defer rbac.InitRoleCache(rbac.RoleCacheOptions{})
+		rbac.InitRoleCache(rbac.RoleCacheOptions{FindPerms: func() ([]*rbac.Role, error) {
+			return []*rbac.Role{mistyped}, nil
+		}})
+		d := rbac.Evaluate([]string{"admin", mistyped.Name}, "service", "delete", nil)
+		assert.False(t, d.Allowed)
+		assert.Equal(t, mistyped.Name, d.Role)
+		assert.True(t, rbac.Evaluate([]string{"admin", mistyped.Name}, "service", "get", nil).Allowed)
+	})
+}
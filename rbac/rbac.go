@@ -34,6 +34,15 @@ const (
 	RoleDeveloper = "developer"
 )
 
+// the resource types of service center and kie, RoleDeveloper can operate all of them
+const (
+	ResourceService    = "service"
+	ResourceInstance   = "instance"
+	ResourceSchema     = "schema"
+	ResourceGovernance = "governance"
+	ResourceConfig     = "config"
+)
+
 var whiteAPIList = mapset.NewSet()
 
 func AccountFromContext(ctx context.Context) (*Account, error) {
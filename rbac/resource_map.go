@@ -18,42 +18,285 @@
 package rbac
 
 import (
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/karlseguin/ccache/v2"
+)
+
+const (
+	resourceMapSize = 10000
+	resourceMapTTL  = time.Hour
 )
 
 // as a user of a backend service, he only understands resource of this service,
 // to decouple authorization code from business code,
 // a middleware should handle all the authorization logic, and this middleware only understand rest API,
 // a resource mapping helps to maintain relations between api and resource.
-var resourceMap = sync.Map{}
+// it is bounded, the least recently used apis are evicted
+var resourceMap = ccache.New(ccache.Configure().MaxSize(resourceMapSize))
 
-// PartialMap saves api partial matching
+// resourceMapGen is increased on each change of the routes, the results of the older generations are stale
+var resourceMapGen uint64
+
+// PartialMap saves api partial matching, the entries are matched after the ones of PartialMapResource
+//
+// Deprecated: use PartialMapResource, writing it is not safe for concurrent use
 var PartialMap = map[string]string{}
 
+var router = &resourceRouter{}
+
+// mappedResource is the result of GetResource in the generation
+type mappedResource struct {
+	gen      uint64
+	resource string
+}
+
+type segmentKind int
+
+const (
+	segmentLiteral segmentKind = iota
+	segmentParam
+	segmentWildcard
+)
+
+// segment is a part of the path template split by "/"
+type segment struct {
+	kind  segmentKind
+	value string // the literal or the param name
+}
+
+type route struct {
+	method   string // empty matches any method
+	segments []segment
+	resource string
+}
+
+type partialRoute struct {
+	api      string
+	resource string
+}
+
+// resourceRouter matches an API to the resource by the path templates,
+// the routes are sorted by precedence, so the first matched route is the most specific one
+type resourceRouter struct {
+	mutex    sync.RWMutex
+	routes   []*route
+	partials []*partialRoute
+}
+
 // GetResource try to find resource by API path, it has preheat mechanism after program start up
 // an API pattern is like /resource/:id/, /resource/{id}/,
-// MUST NOT pass exact resource id to this API like /resource/100, otherwise you are facing massive memory footprint
+// MUST NOT pass exact resource id to this API like /resource/100, otherwise the bounded cache keeps missing,
+// use MatchResource for the request paths instead
 func GetResource(apiPattern string) string {
-	r, ok := resourceMap.Load(apiPattern)
-	if ok {
-		return r.(string)
+	gen := atomic.LoadUint64(&resourceMapGen)
+	if item := resourceMap.Get(apiPattern); item != nil && !item.Expired() {
+		if r := item.Value().(mappedResource); r.gen == gen {
+			return r.resource
+		}
+	}
+	resource := router.resource("", apiPattern)
+	if resource != "" {
+		// stored with the generation before matching, so it is stale if the routes are changed meanwhile
+		resourceMap.Set(apiPattern, mappedResource{gen: gen, resource: resource}, resourceMapTTL)
+	}
+	return resource
+}
+
+// MatchResource returns the resource of the request, the path params are set to the labels of the resource,
+// e.g. the request "GET /v4/default/registry/microservices/1" matches the route
+// "/v4/:project/registry/microservices/:serviceId", and the labels are {"project":"default","serviceId":"1"}.
+// if the method is empty, the routes of any method take precedence over the routes of a method
+func MatchResource(method, path string) (*Resource, bool) {
+	rt, params := router.match(method, path)
+	if rt == nil {
+		return nil, false
+	}
+	return &Resource{Type: rt.resource, Labels: params}, true
+}
+
+// MapResource saves the mapping from api to resource, the api is a path template of any method
+func MapResource(api, resource string) {
+	MapRoute("", api, resource)
+}
+
+// MapRoute saves the mapping from the path template of the method to resource, empty method matches any method.
+// the segment of the template can be a literal, a param like :id or {id}, or * which matches any segment,
+// and matches one or more rest segments if it is the last one.
+// the more specific template takes precedence, a literal segment is more specific than a param or *,
+// a longer template is more specific than the shorter one, and a method is more specific than any method
+func MapRoute(method, pattern, resource string) {
+	router.add(&route{method: strings.ToUpper(method), segments: parseTemplate(pattern), resource: resource})
+	resetResourceMap()
+}
+
+// PartialMapResource saves the mapping from api to resource, it is partial match,
+// it is used only if no route matched, and the longer api takes precedence
+func PartialMapResource(api, resource string) {
+	router.addPartial(&partialRoute{api: api, resource: resource})
+	resetResourceMap()
+}
+
+// resetResourceMap drops the cached results which may be changed by the new route
+func resetResourceMap() {
+	atomic.AddUint64(&resourceMapGen, 1)
+	resourceMap.Clear()
+}
+
+// resetRoutes removes all the routes
+func resetRoutes() {
+	router.mutex.Lock()
+	router.routes, router.partials = nil, nil
+	router.mutex.Unlock()
+	resetResourceMap()
+}
+
+func (r *resourceRouter) add(rt *route) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	i := sort.Search(len(r.routes), func(i int) bool {
+		return !precedes(r.routes[i], rt)
+	})
+	r.routes = append(r.routes, nil)
+	copy(r.routes[i+1:], r.routes[i:])
+	r.routes[i] = rt
+}
+
+func (r *resourceRouter) addPartial(pr *partialRoute) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.partials = append(r.partials, pr)
+	sort.SliceStable(r.partials, func(i, j int) bool {
+		return len(r.partials[i].api) > len(r.partials[j].api)
+	})
+}
+
+func (r *resourceRouter) match(method, path string) (*route, map[string]string) {
+	method = strings.ToUpper(method)
+	segments := splitPath(path)
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	// the route of any method takes precedence if the method is unknown
+	var fallback *route
+	var fallbackParams map[string]string
+	for _, rt := range r.routes {
+		if rt.method != "" && method != "" && rt.method != method {
+			continue
+		}
+		if rt.method != "" && method == "" && fallback != nil {
+			continue
+		}
+		params, ok := rt.match(segments)
+		if !ok {
+			continue
+		}
+		if rt.method != "" && method == "" {
+			fallback, fallbackParams = rt, params
+			continue
+		}
+		return rt, params
+	}
+	return fallback, fallbackParams
+}
+
+func (r *resourceRouter) resource(method, path string) string {
+	if rt, _ := r.match(method, path); rt != nil {
+		return rt.resource
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, pr := range r.partials {
+		if strings.Contains(path, pr.api) {
+			return pr.resource
+		}
 	}
 	for partialAPI, resource := range PartialMap {
-		if strings.Contains(apiPattern, partialAPI) {
-			resourceMap.Store(apiPattern, resource)
+		if strings.Contains(path, partialAPI) {
 			return resource
 		}
 	}
 	return ""
 }
 
-// MapResource saves the mapping from api to resource, it must be exactly match
-func MapResource(api, resource string) {
-	resourceMap.Store(api, resource)
+func (rt *route) match(segments []string) (map[string]string, bool) {
+	var params map[string]string
+	for i, seg := range rt.segments {
+		if i >= len(segments) {
+			return nil, false
+		}
+		if seg.kind == segmentWildcard && i == len(rt.segments)-1 {
+			return params, true
+		}
+		switch seg.kind {
+		case segmentLiteral:
+			if seg.value != segments[i] {
+				return nil, false
+			}
+		case segmentParam:
+			if segments[i] == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[seg.value] = segments[i]
+		}
+	}
+	return params, len(segments) == len(rt.segments)
 }
 
-// PartialMapResource saves the mapping from api to resource, it is partial match
-func PartialMapResource(api, resource string) {
-	PartialMap[api] = resource
+// precedes returns true if a is more specific than b
+func precedes(a, b *route) bool {
+	for i := 0; i < len(a.segments) && i < len(b.segments); i++ {
+		if ka, kb := rank(a, i), rank(b, i); ka != kb {
+			return ka < kb
+		}
+	}
+	if len(a.segments) != len(b.segments) {
+		return len(a.segments) > len(b.segments)
+	}
+	return a.method != "" && b.method == ""
+}
+
+// rank of the segment, the lower the more specific, a trailing * is the least specific
+func rank(rt *route, i int) int {
+	kind := rt.segments[i].kind
+	if kind == segmentWildcard && i == len(rt.segments)-1 {
+		return int(kind) + 1
+	}
+	return int(kind)
+}
+
+func parseTemplate(pattern string) []segment {
+	parts := splitPath(pattern)
+	segments := make([]segment, len(parts))
+	for i, p := range parts {
+		switch {
+		case p == "*":
+			segments[i] = segment{kind: segmentWildcard}
+		case len(p) > 1 && p[0] == ':':
+			segments[i] = segment{kind: segmentParam, value: p[1:]}
+		case len(p) > 2 && p[0] == '{' && p[len(p)-1] == '}':
+			segments[i] = segment{kind: segmentParam, value: p[1 : len(p)-1]}
+		default:
+			segments[i] = segment{kind: segmentLiteral, value: p}
+		}
+	}
+	return segments
+}
+
+// splitPath ignores the query and the leading and trailing "/"
+func splitPath(path string) []string {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
 }
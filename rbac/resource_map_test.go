@@ -24,6 +24,8 @@ import (
 )
 
 func TestGetResource(t *testing.T) {
+	rbac.ResetRoutes()
+	defer rbac.ResetRoutes()
 	t.Run("given empty mapping,return empty resource", func(t *testing.T) {
 		res := rbac.GetResource("/test")
 		assert.Empty(t, res)
@@ -46,4 +48,74 @@ func TestGetResource(t *testing.T) {
 		res = rbac.GetResource("/v1/part/:id")
 		assert.Equal(t, "partRes", res)
 	})
+	t.Run("given deprecated partial map, return right resource", func(t *testing.T) {
+		rbac.PartialMap["legacy/"] = "legacyRes"
+		defer delete(rbac.PartialMap, "legacy/")
+		assert.Equal(t, "legacyRes", rbac.GetResource("/v1/legacy/:id"))
+	})
+	t.Run("given more specific route added after cached, return the new resource", func(t *testing.T) {
+		rbac.MapResource("/v2/order/*", "order")
+		assert.Equal(t, "order", rbac.GetResource("/v2/order/:id/items"))
+		rbac.MapResource("/v2/order/:id/items", "item")
+		assert.Equal(t, "item", rbac.GetResource("/v2/order/:id/items"))
+	})
+	t.Run("given route added while getting, should not keep the stale resource", func(t *testing.T) {
+		rbac.MapResource("/v3/order/*", "order")
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 1000; i++ {
+				rbac.GetResource("/v3/order/:id/items")
+			}
+		}()
+		rbac.MapResource("/v3/order/:id/items", "item")
+		<-done
+		assert.Equal(t, "item", rbac.GetResource("/v3/order/:id/items"))
+	})
+}
+
+func TestMatchResource(t *testing.T) {
+	rbac.ResetRoutes()
+	defer rbac.ResetRoutes()
+	rbac.MapRoute("", "/v4/:project/registry/microservices/:serviceId", "service")
+	rbac.MapRoute("GET", "/v4/{project}/registry/microservices/:serviceId/instances", "instance")
+	rbac.MapRoute("", "/v4/:project/registry/microservices/:serviceId/*", "serviceChild")
+	rbac.MapRoute("", "/v4/:project/registry/microservices/governance", "governance")
+	rbac.MapRoute("delete", "/v4/:project/registry/microservices/:serviceId", "serviceDeletion")
+
+	t.Run("given path params, should extract labels", func(t *testing.T) {
+		res, ok := rbac.MatchResource("GET", "/v4/default/registry/microservices/100?noCache=1")
+		assert.True(t, ok)
+		assert.Equal(t, "service", res.Type)
+		assert.Equal(t, map[string]string{"project": "default", "serviceId": "100"}, res.Labels)
+	})
+	t.Run("given literal and param, literal should take precedence", func(t *testing.T) {
+		res, ok := rbac.MatchResource("GET", "/v4/default/registry/microservices/governance")
+		assert.True(t, ok)
+		assert.Equal(t, "governance", res.Type)
+	})
+	t.Run("given method, should match route of the method", func(t *testing.T) {
+		res, ok := rbac.MatchResource("DELETE", "/v4/default/registry/microservices/100")
+		assert.True(t, ok)
+		assert.Equal(t, "serviceDeletion", res.Type)
+		res, ok = rbac.MatchResource("GET", "/v4/default/registry/microservices/100/instances")
+		assert.True(t, ok)
+		assert.Equal(t, "instance", res.Type)
+	})
+	t.Run("given wildcard, should match the rest segments", func(t *testing.T) {
+		res, ok := rbac.MatchResource("POST", "/v4/default/registry/microservices/100/instances")
+		assert.True(t, ok)
+		assert.Equal(t, "serviceChild", res.Type)
+		res, ok = rbac.MatchResource("GET", "/v4/default/registry/microservices/100/schemas/s1")
+		assert.True(t, ok)
+		assert.Equal(t, "serviceChild", res.Type)
+		assert.Equal(t, "100", res.Labels["serviceId"])
+	})
+	t.Run("given unknown path, should not match", func(t *testing.T) {
+		_, ok := rbac.MatchResource("GET", "/v4/default/registry/instances")
+		assert.False(t, ok)
+	})
+	t.Run("given api pattern, should return resource", func(t *testing.T) {
+		assert.Equal(t, "service", rbac.GetResource("/v4/:project/registry/microservices/:serviceId"))
+	})
 }
@@ -17,15 +17,29 @@
 
 package rbac
 
+import "fmt"
+
 type RoleResponse struct {
 	Total int64   `json:"total,omitempty"`
 	Roles []*Role `json:"data,omitempty"`
 }
 
 type Role struct {
-	ID         string        `json:"id,omitempty"`
-	Name       string        `json:"name,omitempty"`
-	Perms      []*Permission `json:"perms,omitempty"`
-	CreateTime string        `json:"createTime,omitempty"`
-	UpdateTime string        `json:"updateTime,omitempty"`
+	ID    string        `json:"id,omitempty"`
+	Name  string        `json:"name,omitempty"`
+	Perms []*Permission `json:"perms,omitempty"`
+	// Inherits the names of the parent roles, the perms of them are included in the effective perms of the role
+	Inherits   []string `json:"inherits,omitempty"`
+	CreateTime string   `json:"createTime,omitempty"`
+	UpdateTime string   `json:"updateTime,omitempty"`
+}
+
+// Validate checks the perms of the role, see Permission.Validate
+func (r *Role) Validate() error {
+	for _, p := range r.Perms {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("role %s: %w", r.Name, err)
+		}
+	}
+	return nil
 }
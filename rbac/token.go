@@ -0,0 +1,230 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+
+	DefaultTokenTTL = 12 * time.Hour
+)
+
+// TokenKey is a key to sign or verify the tokens, the ID is set as the "kid" header of the tokens it signs.
+// Key is []byte for HS256, *rsa.PrivateKey for RS256 and *ecdsa.PrivateKey for ES256,
+// and it can be *rsa.PublicKey or *ecdsa.PublicKey if the key is only used to verify
+type TokenKey struct {
+	ID        string
+	Algorithm string
+	Key       interface{}
+}
+
+func (k *TokenKey) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+func (k *TokenKey) signKey() (interface{}, bool) {
+	switch key := k.Key.(type) {
+	case []byte:
+		return key, k.Algorithm == AlgHS256
+	case *rsa.PrivateKey:
+		return key, k.Algorithm == AlgRS256
+	case *ecdsa.PrivateKey:
+		return key, k.Algorithm == AlgES256
+	}
+	return nil, false
+}
+
+func (k *TokenKey) verifyKey() (interface{}, bool) {
+	switch key := k.Key.(type) {
+	case []byte:
+		return key, k.Algorithm == AlgHS256
+	case *rsa.PrivateKey:
+		return &key.PublicKey, k.Algorithm == AlgRS256
+	case *rsa.PublicKey:
+		return key, k.Algorithm == AlgRS256
+	case *ecdsa.PrivateKey:
+		return &key.PublicKey, k.Algorithm == AlgES256
+	case *ecdsa.PublicKey:
+		return key, k.Algorithm == AlgES256
+	}
+	return nil, false
+}
+
+// TokenOptions configures the TokenService
+type TokenOptions struct {
+	Issuer string        // set as "iss" claim and verified if not empty
+	TTL    time.Duration // used if Account.TokenExpirationTime is not set, default 12h
+	// Leeway allows the clock skew between the issuer and the verifier
+	Leeway time.Duration
+}
+
+// TokenService issues and verifies the JWT tokens of the accounts. It signs by the current key,
+// and verifies by any of the keys, so the tokens signed by the rotated keys are valid until they expire
+type TokenService struct {
+	opts    TokenOptions
+	mutex   sync.RWMutex
+	keys    map[string]*TokenKey
+	current string
+	now     func() time.Time
+}
+
+// NewTokenService returns a token service which signs by key
+func NewTokenService(opts TokenOptions, key TokenKey) (*TokenService, error) {
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTokenTTL
+	}
+	s := &TokenService{opts: opts, keys: make(map[string]*TokenKey), now: time.Now}
+	if err := s.Rotate(key); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// AddKey adds a key to verify the tokens, e.g. the public key of another issuer
+func (s *TokenService) AddKey(key TokenKey) error {
+	if len(key.ID) == 0 {
+		return errors.New("token key id is empty")
+	}
+	if _, ok := key.verifyKey(); !ok || key.method() == nil {
+		return fmt.Errorf("token key %s: unsupported algorithm %s or key type %T", key.ID, key.Algorithm, key.Key)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys[key.ID] = &key
+	return nil
+}
+
+// Rotate adds the key and signs the new tokens by it, the old keys are kept to verify
+func (s *TokenService) Rotate(key TokenKey) error {
+	if _, ok := key.signKey(); !ok {
+		return fmt.Errorf("token key %s can not sign by %s", key.ID, key.Algorithm)
+	}
+	if err := s.AddKey(key); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.current = key.ID
+	return nil
+}
+
+// RemoveKey removes the key, the tokens signed by it are invalid then. The current key can not be removed
+func (s *TokenService) RemoveKey(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if id == s.current {
+		return fmt.Errorf("can not remove the current token key %s", id)
+	}
+	delete(s.keys, id)
+	return nil
+}
+
+// Issue mints a token of the account, the claims are the account name and roles,
+// it expires after Account.TokenExpirationTime, a duration like "30m", or TokenOptions.TTL if not set
+func (s *TokenService) Issue(a *Account) (*Token, error) {
+	ttl := s.opts.TTL
+	if len(a.TokenExpirationTime) > 0 {
+		d, err := time.ParseDuration(a.TokenExpirationTime)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid token expiration time %q", a.TokenExpirationTime)
+		}
+		ttl = d
+	}
+	roles := a.Roles
+	if len(roles) == 0 && len(a.Role) > 0 {
+		roles = []string{a.Role}
+	}
+
+	s.mutex.RLock()
+	key := s.keys[s.current]
+	s.mutex.RUnlock()
+
+	now := s.now()
+	claims := jwt.MapClaims{
+		ClaimsUser:  a.Name,
+		ClaimsRoles: roles,
+		"iat":       now.Unix(),
+		"exp":       now.Add(ttl).Unix(),
+	}
+	if len(s.opts.Issuer) > 0 {
+		claims["iss"] = s.opts.Issuer
+	}
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	signKey, _ := key.signKey()
+	tokenStr, err := token.SignedString(signKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Token{TokenStr: tokenStr}, nil
+}
+
+// Verify verifies the token and returns its claims, which can be set into the context by NewContext.
+// It returns an ErrTokenExpired error if the token is expired, otherwise an ErrUnauthorized error if it is invalid
+func (s *TokenService) Verify(tokenStr string) (map[string]interface{}, error) {
+	claims := jwt.MapClaims{}
+	parser := &jwt.Parser{SkipClaimsValidation: true} // validated below with the leeway
+	_, err := parser.ParseWithClaims(tokenStr, claims, s.keyFunc)
+	if err != nil {
+		return nil, NewError(ErrUnauthorized, err.Error())
+	}
+
+	now := s.now()
+	if !claims.VerifyExpiresAt(now.Add(-s.opts.Leeway).Unix(), true) {
+		return nil, NewError(ErrTokenExpired, "")
+	}
+	if !claims.VerifyNotBefore(now.Add(s.opts.Leeway).Unix(), false) ||
+		!claims.VerifyIssuedAt(now.Add(s.opts.Leeway).Unix(), false) {
+		return nil, NewError(ErrUnauthorized, "token is used before issued")
+	}
+	if len(s.opts.Issuer) > 0 && !claims.VerifyIssuer(s.opts.Issuer, true) {
+		return nil, NewError(ErrUnauthorized, "token issuer is invalid")
+	}
+	if _, err := GetAccount(claims); err != nil {
+		return nil, NewError(ErrUnauthorized, err.Error())
+	}
+	return claims, nil
+}
+
+func (s *TokenService) keyFunc(token *jwt.Token) (interface{}, error) {
+	id, _ := token.Header["kid"].(string)
+	s.mutex.RLock()
+	key, ok := s.keys[id]
+	s.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("token key %q not found", id)
+	}
+	// the algorithm of the key is used, not the one claimed by the token
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing algorithm %s", token.Method.Alg())
+	}
+	verifyKey, _ := key.verifyKey()
+	return verifyKey, nil
+}
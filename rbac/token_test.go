@@ -0,0 +1,156 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rbac
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/jwt-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/cari/pkg/errsvc"
+)
+
+func TestTokenService(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	for _, key := range []TokenKey{
+		{ID: "hs", Algorithm: AlgHS256, Key: []byte("secret")},
+		{ID: "rs", Algorithm: AlgRS256, Key: rsaKey},
+		{ID: "es", Algorithm: AlgES256, Key: ecKey},
+	} {
+		t.Run(key.Algorithm, func(t *testing.T) {
+			s, err := NewTokenService(TokenOptions{Issuer: "sc"}, key)
+			assert.NoError(t, err)
+			token, err := s.Issue(&Account{Name: "root", Roles: []string{RoleAdmin}})
+			assert.NoError(t, err)
+
+			claims, err := s.Verify(token.TokenStr)
+			assert.NoError(t, err)
+			a, err := AccountFromContext(NewContext(context.Background(), claims))
+			assert.NoError(t, err)
+			assert.Equal(t, "root", a.Name)
+			assert.Equal(t, []string{RoleAdmin}, a.Roles)
+		})
+	}
+}
+
+func TestTokenService_expiration(t *testing.T) {
+	s, err := NewTokenService(TokenOptions{Leeway: time.Minute}, TokenKey{ID: "k1", Algorithm: AlgHS256, Key: []byte("secret")})
+	assert.NoError(t, err)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	token, err := s.Issue(&Account{Name: "root", Role: RoleAdmin, TokenExpirationTime: "30m"})
+	assert.NoError(t, err)
+	s.now = func() time.Time { return now.Add(30 * time.Minute) }
+	_, err = s.Verify(token.TokenStr)
+	assert.NoError(t, err, "should allow the leeway")
+	s.now = func() time.Time { return now.Add(32 * time.Minute) }
+	_, err = s.Verify(token.TokenStr)
+	assert.True(t, errsvc.IsErrEqualCode(err, ErrTokenExpired))
+
+	s.now = func() time.Time { return now }
+	token, err = s.Issue(&Account{Name: "root", Roles: []string{RoleAdmin}})
+	assert.NoError(t, err)
+	s.now = func() time.Time { return now.Add(DefaultTokenTTL - time.Minute) }
+	_, err = s.Verify(token.TokenStr)
+	assert.NoError(t, err, "should use the default ttl")
+
+	_, err = s.Issue(&Account{Name: "root", TokenExpirationTime: "1 day"})
+	assert.Error(t, err)
+}
+
+func TestTokenService_rotate(t *testing.T) {
+	s, err := NewTokenService(TokenOptions{}, TokenKey{ID: "k1", Algorithm: AlgHS256, Key: []byte("secret1")})
+	assert.NoError(t, err)
+	old, err := s.Issue(&Account{Name: "root", Roles: []string{RoleAdmin}})
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Rotate(TokenKey{ID: "k2", Algorithm: AlgHS256, Key: []byte("secret2")}))
+	token, err := s.Issue(&Account{Name: "root", Roles: []string{RoleAdmin}})
+	assert.NoError(t, err)
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token.TokenStr, jwt.MapClaims{})
+	assert.NoError(t, err)
+	assert.Equal(t, "k2", parsed.Header["kid"])
+	_, err = s.Verify(old.TokenStr)
+	assert.NoError(t, err, "token of the old key should be valid")
+
+	assert.Error(t, s.RemoveKey("k2"))
+	assert.NoError(t, s.RemoveKey("k1"))
+	_, err = s.Verify(old.TokenStr)
+	assert.True(t, errsvc.IsErrEqualCode(err, ErrUnauthorized))
+}
+
+func TestTokenService_invalid(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	_, err = NewTokenService(TokenOptions{}, TokenKey{ID: "k1", Algorithm: AlgRS256, Key: &rsaKey.PublicKey})
+	assert.Error(t, err, "public key can not sign")
+	_, err = NewTokenService(TokenOptions{}, TokenKey{ID: "k1", Algorithm: AlgHS256, Key: rsaKey})
+	assert.Error(t, err, "key type mismatched")
+	_, err = NewTokenService(TokenOptions{}, TokenKey{Algorithm: AlgHS256, Key: []byte("secret")})
+	assert.Error(t, err, "no key id")
+
+	s, err := NewTokenService(TokenOptions{Issuer: "sc"}, TokenKey{ID: "rs", Algorithm: AlgRS256, Key: rsaKey})
+	assert.NoError(t, err)
+	token, err := s.Issue(&Account{Name: "root", Roles: []string{RoleAdmin}})
+	assert.NoError(t, err)
+
+	t.Run("tampered, should be unauthorized", func(t *testing.T) {
+		parts := strings.Split(token.TokenStr, ".")
+		parts[2] = parts[2][:len(parts[2])-4] + "AAAA"
+		_, err := s.Verify(strings.Join(parts, "."))
+		assert.True(t, errsvc.IsErrEqualCode(err, ErrUnauthorized))
+	})
+	t.Run("algorithm confusion, should be unauthorized", func(t *testing.T) {
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			ClaimsUser: "root", ClaimsRoles: []string{RoleAdmin}, "exp": time.Now().Add(time.Hour).Unix(), "iss": "sc",
+		})
+		forged.Header["kid"] = "rs"
+		tokenStr, err := forged.SignedString([]byte("anything"))
+		assert.NoError(t, err)
+		_, err = s.Verify(tokenStr)
+		assert.True(t, errsvc.IsErrEqualCode(err, ErrUnauthorized))
+	})
+	t.Run("another issuer, should be unauthorized", func(t *testing.T) {
+		another, err := NewTokenService(TokenOptions{Issuer: "kie"}, TokenKey{ID: "rs", Algorithm: AlgRS256, Key: rsaKey})
+		assert.NoError(t, err)
+		token, err := another.Issue(&Account{Name: "root", Roles: []string{RoleAdmin}})
+		assert.NoError(t, err)
+		_, err = s.Verify(token.TokenStr)
+		assert.True(t, errsvc.IsErrEqualCode(err, ErrUnauthorized))
+	})
+	t.Run("verify only key, should verify", func(t *testing.T) {
+		verifier, err := NewTokenService(TokenOptions{Issuer: "sc"}, TokenKey{ID: "hs", Algorithm: AlgHS256, Key: []byte("s")})
+		assert.NoError(t, err)
+		assert.NoError(t, verifier.AddKey(TokenKey{ID: "rs", Algorithm: AlgRS256, Key: &rsaKey.PublicKey}))
+		_, err = verifier.Verify(token.TokenStr)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,139 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package security
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	AlgAESGCM           = "aesgcm"
+	AlgChaCha20Poly1305 = "chacha20poly1305"
+)
+
+// ErrInvalidCiphertext is returned when the ciphertext is not made by the AEADCipher
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// AEADCipher is a Cipher of authenticated encryption, the ciphertext is in the format of
+// "alg:key id:base64(nonce + sealed)", the alg and key id are authenticated too
+type AEADCipher struct {
+	alg     string
+	newAEAD func(key []byte) (cipher.AEAD, error)
+	keyring *Keyring
+}
+
+// NewAESGCMCipher returns an AES-GCM cipher of the keys, the keys must be 16, 24 or 32 bytes
+func NewAESGCMCipher(keyring *Keyring) *AEADCipher {
+	return &AEADCipher{
+		alg:     AlgAESGCM,
+		newAEAD: newAESGCM,
+		keyring: keyring,
+	}
+}
+
+// NewChaCha20Poly1305Cipher returns a ChaCha20-Poly1305 cipher of the keys, the keys must be 32 bytes
+func NewChaCha20Poly1305Cipher(keyring *Keyring) *AEADCipher {
+	return &AEADCipher{
+		alg:     AlgChaCha20Poly1305,
+		newAEAD: chacha20poly1305.New,
+		keyring: keyring,
+	}
+}
+
+// Encrypt encrypts src by the primary key
+func (c *AEADCipher) Encrypt(src string) (string, error) {
+	id, key := c.keyring.Primary()
+	aead, err := c.newAEAD(key)
+	if err != nil {
+		return "", fmt.Errorf("key %s: %w", id, err)
+	}
+	header := c.alg + ":" + id
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(src)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(src), []byte(header))
+	return header + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts src by the key of the id in it
+func (c *AEADCipher) Decrypt(src string) (string, error) {
+	id, sealed, err := c.parse(src)
+	if err != nil {
+		return "", err
+	}
+	key, err := c.keyring.Get(id)
+	if err != nil {
+		return "", err
+	}
+	aead, err := c.newAEAD(key)
+	if err != nil {
+		return "", fmt.Errorf("key %s: %w", id, err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, []byte(c.alg+":"+id))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidCiphertext, err)
+	}
+	return string(plain), nil
+}
+
+// NeedsReencrypt reports whether src is not encrypted by the primary key,
+// the callers can re-encrypt the stored ciphertext lazily when they read it
+func (c *AEADCipher) NeedsReencrypt(src string) bool {
+	id, _, err := c.parse(src)
+	if err != nil {
+		return false
+	}
+	primary, _ := c.keyring.Primary()
+	return id != primary
+}
+
+// Reencrypt decrypts src and encrypts it by the primary key, src is returned as is if it needs not
+func (c *AEADCipher) Reencrypt(src string) (string, error) {
+	if !c.NeedsReencrypt(src) {
+		return src, nil
+	}
+	plain, err := c.Decrypt(src)
+	if err != nil {
+		return "", err
+	}
+	return c.Encrypt(plain)
+}
+
+func (c *AEADCipher) parse(src string) (string, []byte, error) {
+	parts := strings.SplitN(src, ":", 3)
+	if len(parts) != 3 || parts[0] != c.alg {
+		return "", nil, ErrInvalidCiphertext
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, ErrInvalidCiphertext
+	}
+	return parts[1], sealed, nil
+}
@@ -0,0 +1,110 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package security_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/cari/security"
+)
+
+func newKey(id string, b byte) security.Key {
+	return security.Key{ID: id, Material: bytes.Repeat([]byte{b}, 32)}
+}
+
+func TestAEADCipher(t *testing.T) {
+	for name, newCipher := range map[string]func(*security.Keyring) *security.AEADCipher{
+		security.AlgAESGCM:           security.NewAESGCMCipher,
+		security.AlgChaCha20Poly1305: security.NewChaCha20Poly1305Cipher,
+	} {
+		t.Run(name, func(t *testing.T) {
+			keyring, err := security.NewKeyring(newKey("k1", 1))
+			assert.NoError(t, err)
+			var c security.Cipher = newCipher(keyring)
+
+			encrypted, err := c.Encrypt("Pwd@1234")
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(encrypted, name+":k1:"))
+			another, err := c.Encrypt("Pwd@1234")
+			assert.NoError(t, err)
+			assert.NotEqual(t, encrypted, another, "nonce should be random")
+			plain, err := c.Decrypt(encrypted)
+			assert.NoError(t, err)
+			assert.Equal(t, "Pwd@1234", plain)
+
+			t.Run("tampered, should fail", func(t *testing.T) {
+				_, err := c.Decrypt(encrypted[:len(encrypted)-2] + "AA")
+				assert.ErrorIs(t, err, security.ErrInvalidCiphertext)
+				_, err = c.Decrypt(strings.Replace(encrypted, ":k1:", ":k2:", 1))
+				assert.ErrorIs(t, err, security.ErrKeyNotFound)
+				_, err = c.Decrypt("plain")
+				assert.ErrorIs(t, err, security.ErrInvalidCiphertext)
+			})
+		})
+	}
+}
+
+func TestAEADCipher_rotate(t *testing.T) {
+	keyring, err := security.NewKeyring(newKey("k1", 1))
+	assert.NoError(t, err)
+	c := security.NewAESGCMCipher(keyring)
+	old, err := c.Encrypt("secret")
+	assert.NoError(t, err)
+	assert.False(t, c.NeedsReencrypt(old))
+
+	assert.NoError(t, keyring.Rotate(newKey("k2", 2)))
+	plain, err := c.Decrypt(old)
+	assert.NoError(t, err, "old ciphertext should decrypt after rotation")
+	assert.Equal(t, "secret", plain)
+	assert.True(t, c.NeedsReencrypt(old))
+
+	renewed, err := c.Reencrypt(old)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(renewed, "aesgcm:k2:"))
+	assert.False(t, c.NeedsReencrypt(renewed))
+	same, err := c.Reencrypt(renewed)
+	assert.NoError(t, err)
+	assert.Equal(t, renewed, same)
+
+	assert.Error(t, keyring.Remove("k2"), "should not remove primary key")
+	assert.NoError(t, keyring.Remove("k1"))
+	_, err = c.Decrypt(old)
+	assert.ErrorIs(t, err, security.ErrKeyNotFound)
+}
+
+func TestNewKeyring(t *testing.T) {
+	_, err := security.NewKeyring()
+	assert.Equal(t, security.ErrNoKey, err)
+	_, err = security.NewKeyring(newKey("a:b", 1))
+	assert.Equal(t, security.ErrInvalidKeyID, err)
+	_, err = security.NewKeyring(security.Key{ID: "k1"})
+	assert.Error(t, err)
+
+	keyring, err := security.NewKeyring(newKey("k1", 1))
+	assert.NoError(t, err)
+	_, err = security.NewAESGCMCipher(keyring).Encrypt("s")
+	assert.NoError(t, err)
+	keyring, err = security.NewKeyring(security.Key{ID: "short", Material: []byte("123")})
+	assert.NoError(t, err)
+	_, err = security.NewAESGCMCipher(keyring).Encrypt("s")
+	assert.Error(t, err, "invalid key size")
+}
@@ -0,0 +1,151 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package security
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	envelopePrefix         = "envelope"
+	dataKeySize            = 32
+	defaultProviderTimeout = 10 * time.Second
+)
+
+// KeyProvider holds the master keys, it wraps and unwraps the data keys, e.g. a KMS
+type KeyProvider interface {
+	// Wrap encrypts the data key by the current master key, returns the ID of the master key and the wrapped key
+	Wrap(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// Unwrap decrypts the wrapped data key by the master key of the keyID
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// EnvelopeCipher encrypts each message by a random data key with AES-GCM,
+// and stores the data key wrapped by the KeyProvider along with the ciphertext,
+// so the master key never leaves the provider and can be rotated there
+type EnvelopeCipher struct {
+	provider KeyProvider
+	timeout  time.Duration
+}
+
+// NewEnvelopeCipher returns an envelope cipher of the provider,
+// timeout is the deadline of each call to the provider, default 10s
+func NewEnvelopeCipher(provider KeyProvider, timeout time.Duration) *EnvelopeCipher {
+	if timeout <= 0 {
+		timeout = defaultProviderTimeout
+	}
+	return &EnvelopeCipher{provider: provider, timeout: timeout}
+}
+
+// Encrypt encrypts src, the ciphertext is in the format of
+// "envelope:base64(master key id):base64(wrapped data key):base64(nonce + sealed)"
+func (c *EnvelopeCipher) Encrypt(src string) (string, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	keyID, wrapped, err := c.provider.Wrap(ctx, dataKey)
+	if err != nil {
+		return "", fmt.Errorf("wrap data key: %w", err)
+	}
+
+	header := envelopePrefix + ":" + base64.RawURLEncoding.EncodeToString([]byte(keyID)) +
+		":" + base64.RawURLEncoding.EncodeToString(wrapped)
+	sealed, err := sealAESGCM(dataKey, []byte(src), []byte(header))
+	if err != nil {
+		return "", err
+	}
+	return header + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts src by the data key unwrapped by the provider
+func (c *EnvelopeCipher) Decrypt(src string) (string, error) {
+	parts := strings.Split(src, ":")
+	if len(parts) != 4 || parts[0] != envelopePrefix {
+		return "", ErrInvalidCiphertext
+	}
+	keyID, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	wrapped, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	dataKey, err := c.provider.Unwrap(ctx, string(keyID), wrapped)
+	if err != nil {
+		return "", fmt.Errorf("unwrap data key: %w", err)
+	}
+	plain, err := openAESGCM(dataKey, sealed, []byte(strings.Join(parts[:3], ":")))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// sealAESGCM returns nonce + sealed
+func sealAESGCM(key, plain, additional []byte) ([]byte, error) {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, additional), nil
+}
+
+func openAESGCM(key, sealed, additional []byte) ([]byte, error) {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additional)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCiphertext, err)
+	}
+	return plain, nil
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
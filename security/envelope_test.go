@@ -0,0 +1,183 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package security_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/cari/security"
+)
+
+// newKMS is a stand-in of the KMS, it wraps the data keys by a local provider
+func newKMS(t *testing.T, token string) *httptest.Server {
+	return httptest.NewServer(kmsHandler(t, token))
+}
+
+func kmsHandler(t *testing.T, token string) http.Handler {
+	keyring, err := security.NewKeyring(newKey("master-v1", 1))
+	assert.NoError(t, err)
+	local := security.NewLocalKeyProvider(keyring)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte("bad token"))
+			return
+		}
+		var req struct {
+			KeyID      string `json:"keyId"`
+			Plaintext  []byte `json:"plaintext"`
+			Ciphertext []byte `json:"ciphertext"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp := make(map[string]interface{})
+		switch r.URL.Path {
+		case "/v1/wrap":
+			assert.Equal(t, "master", req.KeyID)
+			id, wrapped, err := local.Wrap(r.Context(), req.Plaintext)
+			assert.NoError(t, err)
+			resp["keyId"], resp["ciphertext"] = id, wrapped
+		case "/v1/unwrap":
+			plain, err := local.Unwrap(r.Context(), req.KeyID, req.Ciphertext)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			resp["plaintext"] = plain
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
+
+func TestEnvelopeCipher_local(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	assert.NoError(t, os.WriteFile(path, []byte("k1:"+base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))), 0600))
+	provider, err := security.NewFileKeyProvider(path)
+	assert.NoError(t, err)
+
+	var c security.Cipher = security.NewEnvelopeCipher(provider, 0)
+	encrypted, err := c.Encrypt("secret value")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, "envelope:"))
+	assert.NotContains(t, encrypted, "secret")
+	plain, err := c.Decrypt(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret value", plain)
+
+	t.Run("tampered, should fail", func(t *testing.T) {
+		parts := strings.Split(encrypted, ":")
+		another, err := c.Encrypt("another")
+		assert.NoError(t, err)
+		// swap the data key of another message
+		parts[2] = strings.Split(another, ":")[2]
+		_, err = c.Decrypt(strings.Join(parts, ":"))
+		assert.ErrorIs(t, err, security.ErrInvalidCiphertext)
+		_, err = c.Decrypt("envelope:a:b")
+		assert.ErrorIs(t, err, security.ErrInvalidCiphertext)
+	})
+}
+
+func TestEnvelopeCipher_http(t *testing.T) {
+	kms := newKMS(t, "token")
+	defer kms.Close()
+
+	provider, err := security.NewHTTPKeyProvider(security.HTTPKeyProviderOptions{
+		Endpoint: kms.URL + "/v1/",
+		KeyID:    "master",
+		Headers:  http.Header{"Authorization": []string{"Bearer token"}},
+	})
+	assert.NoError(t, err)
+	c := security.NewEnvelopeCipher(provider, time.Second)
+	encrypted, err := c.Encrypt("secret value")
+	assert.NoError(t, err)
+	plain, err := c.Decrypt(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret value", plain)
+
+	keyID, _, err := provider.Wrap(context.Background(), bytes.Repeat([]byte{3}, 32))
+	assert.NoError(t, err)
+	assert.Equal(t, "master-v1", keyID, "should use the key id returned by KMS")
+
+	t.Run("unauthorized, should return error", func(t *testing.T) {
+		provider, err := security.NewHTTPKeyProvider(security.HTTPKeyProviderOptions{Endpoint: kms.URL + "/v1", KeyID: "master"})
+		assert.NoError(t, err)
+		c := security.NewEnvelopeCipher(provider, time.Second)
+		_, err = c.Encrypt("secret value")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "bad token")
+		_, err = c.Decrypt(encrypted)
+		assert.Error(t, err)
+	})
+	t.Run("no endpoint, should return error", func(t *testing.T) {
+		_, err := security.NewHTTPKeyProvider(security.HTTPKeyProviderOptions{})
+		assert.Error(t, err)
+	})
+}
+
+func TestEnvelopeCipher_https_concurrent(t *testing.T) {
+	kms := httptest.NewTLSServer(kmsHandler(t, "token"))
+	defer kms.Close()
+	certPool := x509.NewCertPool()
+	certPool.AddCert(kms.Certificate())
+
+	provider, err := security.NewHTTPKeyProvider(security.HTTPKeyProviderOptions{
+		Endpoint:  kms.URL + "/v1",
+		KeyID:     "master",
+		TLSConfig: &tls.Config{RootCAs: certPool},
+		SignRequest: func(r *http.Request) error {
+			r.Header.Set("Authorization", "Bearer token")
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+	c := security.NewEnvelopeCipher(provider, 5*time.Second)
+	// each goroutine wraps a fresh data key and unwraps it over the same https client
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			encrypted, err := c.Encrypt("secret value")
+			assert.NoError(t, err)
+			plain, err := c.Decrypt(encrypted)
+			assert.NoError(t, err)
+			assert.Equal(t, "secret value", plain)
+		}()
+	}
+	wg.Wait()
+}
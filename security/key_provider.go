@@ -0,0 +1,186 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package security
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const maxKMSResponseSize = 1 << 20
+
+// LocalKeyProvider wraps the data keys by the AES keys of a keyring with AES-GCM,
+// the primary key wraps and all the keys unwrap
+type LocalKeyProvider struct {
+	keyring *Keyring
+}
+
+// NewLocalKeyProvider returns a provider of the keyring, the keys must be 16, 24 or 32 bytes
+func NewLocalKeyProvider(keyring *Keyring) *LocalKeyProvider {
+	return &LocalKeyProvider{keyring: keyring}
+}
+
+// NewFileKeyProvider returns a local provider of the keys in the file, see FileKeyLoader for the file format
+func NewFileKeyProvider(path string) (*LocalKeyProvider, error) {
+	keyring, err := LoadKeyring(FileKeyLoader(path))
+	if err != nil {
+		return nil, err
+	}
+	return NewLocalKeyProvider(keyring), nil
+}
+
+// Wrap implements KeyProvider
+func (p *LocalKeyProvider) Wrap(_ context.Context, dataKey []byte) (string, []byte, error) {
+	id, key := p.keyring.Primary()
+	wrapped, err := sealAESGCM(key, dataKey, []byte(id))
+	if err != nil {
+		return "", nil, fmt.Errorf("key %s: %w", id, err)
+	}
+	return id, wrapped, nil
+}
+
+// Unwrap implements KeyProvider
+func (p *LocalKeyProvider) Unwrap(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	key, err := p.keyring.Get(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return openAESGCM(key, wrapped, []byte(keyID))
+}
+
+// HTTPKeyProviderOptions configures the HTTPKeyProvider
+type HTTPKeyProviderOptions struct {
+	// Endpoint the base url of the KMS, e.g. https://kms.example.com/v1
+	Endpoint string
+	// KeyID the ID of the master key to wrap, the KMS may return another one, e.g. a version of it
+	KeyID string
+	// TLSConfig is used to connect the KMS
+	TLSConfig *tls.Config
+	// Headers are added to each request, e.g. Authorization header
+	Headers http.Header
+	// SignRequest is called with the encoded wrap or unwrap request, e.g. to add a short-lived token or
+	// a signature over the method, path and headers required by the KMS
+	SignRequest func(*http.Request) error
+}
+
+// HTTPKeyProvider wraps and unwraps the data keys by a KMS over http, the api is
+//
+//	POST {Endpoint}/wrap   {"keyId": "...", "plaintext": "base64"}  -> {"keyId": "...", "ciphertext": "base64"}
+//	POST {Endpoint}/unwrap {"keyId": "...", "ciphertext": "base64"} -> {"plaintext": "base64"}
+//
+// a status other than 200 is an error
+type HTTPKeyProvider struct {
+	opts   HTTPKeyProviderOptions
+	client *http.Client
+}
+
+type kmsRequest struct {
+	KeyID      string `json:"keyId"`
+	Plaintext  []byte `json:"plaintext,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+}
+
+type kmsResponse struct {
+	KeyID      string `json:"keyId,omitempty"`
+	Plaintext  []byte `json:"plaintext,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+}
+
+// NewHTTPKeyProvider returns a provider of the KMS
+func NewHTTPKeyProvider(opts HTTPKeyProviderOptions) (*HTTPKeyProvider, error) {
+	if len(opts.Endpoint) == 0 {
+		return nil, fmt.Errorf("KMS endpoint is empty")
+	}
+	opts.Endpoint = strings.TrimSuffix(opts.Endpoint, "/")
+	opts.Headers = opts.Headers.Clone()
+	// Wrap and Unwrap reuse the connections to the KMS, they are canceled by the ctx passed in
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = opts.TLSConfig
+	return &HTTPKeyProvider{opts: opts, client: &http.Client{Transport: transport}}, nil
+}
+
+// Wrap implements KeyProvider
+func (p *HTTPKeyProvider) Wrap(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	resp, err := p.call(ctx, "/wrap", &kmsRequest{KeyID: p.opts.KeyID, Plaintext: dataKey})
+	if err != nil {
+		return "", nil, err
+	}
+	if len(resp.Ciphertext) == 0 {
+		return "", nil, fmt.Errorf("KMS returns empty ciphertext")
+	}
+	keyID := resp.KeyID
+	if len(keyID) == 0 {
+		keyID = p.opts.KeyID
+	}
+	return keyID, resp.Ciphertext, nil
+}
+
+// Unwrap implements KeyProvider
+func (p *HTTPKeyProvider) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	resp, err := p.call(ctx, "/unwrap", &kmsRequest{KeyID: keyID, Ciphertext: wrapped})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Plaintext) == 0 {
+		return nil, fmt.Errorf("KMS returns empty plaintext")
+	}
+	return resp.Plaintext, nil
+}
+
+func (p *HTTPKeyProvider) call(ctx context.Context, api string, req *kmsRequest) (*kmsResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.opts.Endpoint+api, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if p.opts.Headers != nil {
+		httpReq.Header = p.opts.Headers.Clone()
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.opts.SignRequest != nil {
+		if err := p.opts.SignRequest(httpReq); err != nil {
+			return nil, fmt.Errorf("sign KMS request failed: %w", err)
+		}
+	}
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxKMSResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("KMS %s status: %s, resp: %s", api, resp.Status, string(bytes.TrimSpace(respBody)))
+	}
+	result := &kmsResponse{}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return nil, fmt.Errorf("KMS %s resp: %w", api, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,185 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package security
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrKeyNotFound  = errors.New("key not found")
+	ErrInvalidKeyID = errors.New("key id must be non-empty and must not contain ':'")
+	ErrNoKey        = errors.New("no key is loaded")
+)
+
+// Key is a symmetric key and its ID, the ID is carried by the ciphertext to find the key to decrypt
+type Key struct {
+	ID       string
+	Material []byte
+}
+
+// Keyring holds the keys, the primary key encrypts, and all the keys decrypt,
+// so the ciphertext of the old keys still decrypts after the rotation
+type Keyring struct {
+	mutex   sync.RWMutex
+	keys    map[string][]byte
+	primary string
+}
+
+// NewKeyring returns a keyring of the keys, the first one is the primary key
+func NewKeyring(keys ...Key) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKey
+	}
+	k := &Keyring{keys: make(map[string][]byte, len(keys))}
+	for _, key := range keys {
+		if err := k.Add(key); err != nil {
+			return nil, err
+		}
+	}
+	k.primary = keys[0].ID
+	return k, nil
+}
+
+// Add adds a key to decrypt, the primary key is not changed
+func (k *Keyring) Add(key Key) error {
+	if len(key.ID) == 0 || strings.Contains(key.ID, ":") {
+		return ErrInvalidKeyID
+	}
+	if len(key.Material) == 0 {
+		return fmt.Errorf("key %s is empty", key.ID)
+	}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.keys[key.ID] = append([]byte(nil), key.Material...)
+	return nil
+}
+
+// Rotate adds the key and makes it the primary key, the old keys are kept to decrypt
+func (k *Keyring) Rotate(key Key) error {
+	if err := k.Add(key); err != nil {
+		return err
+	}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.primary = key.ID
+	return nil
+}
+
+// Remove removes the key, the primary key can not be removed
+func (k *Keyring) Remove(id string) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if id == k.primary {
+		return fmt.Errorf("can not remove the primary key %s", id)
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+// Primary returns the ID and material of the primary key
+func (k *Keyring) Primary() (string, []byte) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.primary, k.keys[k.primary]
+}
+
+// Get returns the material of the key
+func (k *Keyring) Get(id string) ([]byte, error) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	material, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	}
+	return material, nil
+}
+
+// KeyLoader loads the keys, the first one is the primary key
+type KeyLoader interface {
+	LoadKeys() ([]Key, error)
+}
+
+// KeyLoaderFunc is an adapter to use a function as a KeyLoader, e.g. to load the keys from a secret manager
+type KeyLoaderFunc func() ([]Key, error)
+
+// LoadKeys calls f()
+func (f KeyLoaderFunc) LoadKeys() ([]Key, error) {
+	return f()
+}
+
+// LoadKeyring returns a keyring of the keys loaded by l
+func LoadKeyring(l KeyLoader) (*Keyring, error) {
+	keys, err := l.LoadKeys()
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyring(keys...)
+}
+
+// FileKeyLoader loads the keys from a file, one key a line in the format of "id:base64 key",
+// the first key is the primary key, the empty lines and the lines start with '#' are ignored
+func FileKeyLoader(path string) KeyLoader {
+	return KeyLoaderFunc(func() ([]Key, error) {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return ParseKeys(strings.Split(string(content), "\n"))
+	})
+}
+
+// EnvKeyLoader loads the keys from an env var, the keys are separated by ',' in the format of FileKeyLoader
+func EnvKeyLoader(name string) KeyLoader {
+	return KeyLoaderFunc(func() ([]Key, error) {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("env %s is not set", name)
+		}
+		return ParseKeys(strings.Split(v, ","))
+	})
+}
+
+// ParseKeys parses the keys in the format of "id:base64 key"
+func ParseKeys(lines []string) ([]Key, error) {
+	keys := make([]Key, 0, len(lines))
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("key %d is not in the format of id:base64 key", i+1)
+		}
+		material, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("decode key %s: %w", id, err)
+		}
+		keys = append(keys, Key{ID: strings.TrimSpace(id), Material: material})
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoKey
+	}
+	return keys, nil
+}
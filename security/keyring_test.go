@@ -0,0 +1,76 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package security_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/cari/security"
+)
+
+func TestFileKeyLoader(t *testing.T) {
+	k1 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	k2 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+	path := filepath.Join(t.TempDir(), "keys")
+	assert.NoError(t, os.WriteFile(path, []byte("# rotated at 2026-10-01\nk2:"+k2+"\n\nk1:"+k1+"\n"), 0600))
+
+	keyring, err := security.LoadKeyring(security.FileKeyLoader(path))
+	assert.NoError(t, err)
+	id, material := keyring.Primary()
+	assert.Equal(t, "k2", id)
+	assert.Equal(t, bytes.Repeat([]byte{2}, 32), material)
+	material, err = keyring.Get("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte{1}, 32), material)
+
+	_, err = security.LoadKeyring(security.FileKeyLoader(filepath.Join(t.TempDir(), "not-exist")))
+	assert.Error(t, err)
+}
+
+func TestEnvKeyLoader(t *testing.T) {
+	k1 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	t.Setenv("CARI_TEST_KEYS", "k1:"+k1+", k0:"+k1)
+	keys, err := security.EnvKeyLoader("CARI_TEST_KEYS").LoadKeys()
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, "k1", keys[0].ID)
+
+	_, err = security.EnvKeyLoader("CARI_TEST_KEYS_NOT_SET").LoadKeys()
+	assert.Error(t, err)
+	t.Setenv("CARI_TEST_KEYS", "k1")
+	_, err = security.EnvKeyLoader("CARI_TEST_KEYS").LoadKeys()
+	assert.Error(t, err)
+	t.Setenv("CARI_TEST_KEYS", "")
+	_, err = security.EnvKeyLoader("CARI_TEST_KEYS").LoadKeys()
+	assert.Equal(t, security.ErrNoKey, err)
+}
+
+func TestKeyLoaderFunc(t *testing.T) {
+	keyring, err := security.LoadKeyring(security.KeyLoaderFunc(func() ([]security.Key, error) {
+		return []security.Key{{ID: "k1", Material: bytes.Repeat([]byte{1}, 32)}}, nil
+	}))
+	assert.NoError(t, err)
+	id, _ := keyring.Primary()
+	assert.Equal(t, "k1", id)
+}
@@ -18,10 +18,11 @@
 package sync
 
 import (
-	"encoding/json"
 	"time"
 
 	"github.com/gofrs/uuid"
+
+	"github.com/go-chassis/cari/codec"
 )
 
 const (
@@ -29,8 +30,17 @@ const (
 	DoneStatus    = "done"
 )
 
+// resourceCodec decodes the resources of both the enveloped and the raw json
+var resourceCodec = codec.MustNewEnvelopeCodec(codec.EnvelopeOptions{Codec: codec.NameJSON})
+
 // NewTask return task with domain, project , action , resourceType and resource
 func NewTask(domain, project, action, resourceType string, resource interface{}) (*Task, error) {
+	return NewTaskWithCodec(domain, project, action, resourceType, resource, codec.JSON{})
+}
+
+// NewTaskWithCodec is like NewTask, but the resource is encoded by c, e.g. a codec.EnvelopeCodec
+// to carry the schema version and checksum to the peer clusters
+func NewTaskWithCodec(domain, project, action, resourceType string, resource interface{}, c codec.Codec) (*Task, error) {
 	taskId, err := uuid.NewV4()
 	if err != nil {
 		return nil, err
@@ -49,7 +59,7 @@ func NewTask(domain, project, action, resourceType string, resource interface{})
 	case []byte:
 		resourceValue = rv
 	default:
-		resourceValue, err = json.Marshal(resource)
+		resourceValue, err = c.Encode(resource)
 		if err != nil {
 			return nil, err
 		}
@@ -67,3 +77,9 @@ func NewTask(domain, project, action, resourceType string, resource interface{})
 		Status:       PendingStatus,
 	}, nil
 }
+
+// DecodeResource decodes the resource of the task into v, the resource wrapped in an envelope
+// is decoded by the codec in the envelope, otherwise it is decoded as json
+func (t *Task) DecodeResource(v interface{}) error {
+	return resourceCodec.Decode(t.Resource, v)
+}
@@ -22,6 +22,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/go-chassis/cari/codec"
 )
 
 func TestNewTask(t *testing.T) {
@@ -59,3 +61,43 @@ func TestNewTask(t *testing.T) {
 		}
 	})
 }
+
+func TestTask_DecodeResource(t *testing.T) {
+	r := map[string]string{
+		"a": "b",
+	}
+	envelopeCodec, err := codec.NewEnvelopeCodec(codec.EnvelopeOptions{
+		Codec:         codec.NameMsgpack,
+		SchemaVersion: 2,
+		Compressor:    codec.Gzip{},
+	})
+	assert.NoError(t, err)
+
+	t.Run("resource is enveloped, should decode by the codec in envelope", func(t *testing.T) {
+		task, err := NewTaskWithCodec("", "", "", "", r, envelopeCodec)
+		if assert.Nil(t, err) {
+			assert.True(t, codec.IsEnveloped(task.Resource))
+			var got map[string]string
+			assert.NoError(t, task.DecodeResource(&got))
+			assert.Equal(t, r, got)
+		}
+	})
+
+	t.Run("resource is json of an old peer, should decode as json", func(t *testing.T) {
+		task, err := NewTask("", "", "", "", r)
+		if assert.Nil(t, err) {
+			var got map[string]string
+			assert.NoError(t, task.DecodeResource(&got))
+			assert.Equal(t, r, got)
+		}
+	})
+
+	t.Run("resource is corrupted, should return error", func(t *testing.T) {
+		task, err := NewTaskWithCodec("", "", "", "", r, envelopeCodec)
+		if assert.Nil(t, err) {
+			task.Resource[len(task.Resource)-5] ^= 0xff
+			var got map[string]string
+			assert.Equal(t, codec.ErrChecksumMismatch, task.DecodeResource(&got))
+		}
+	})
+}